@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+type chrometraceCmd struct {
+}
+
+func (c *chrometraceCmd) Name() string {
+	return "chrometrace"
+}
+
+func (c *chrometraceCmd) Description() string {
+	return "exports the trace as Chrome/Perfetto trace JSON, for interactive exploration in ui.perfetto.dev"
+}
+
+func (c *chrometraceCmd) Run(args []string) error {
+	fs := subcommandFlags(c)
+	outputFile := fs.String("output", "", "where to write the trace JSON")
+	mm := fs.Bool("mmap", false, "memory-map the trace file instead of reading it through the file descriptor")
+	fromExecTrace := fs.Bool("from-exec-trace", false, "treat the trace as an execution trace collected with GODEBUG=traceallocfree=1, rather than a standalone page trace")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("chrometrace expected one argument: a trace")
+	}
+	if *outputFile == "" {
+		return fmt.Errorf("must specify -output")
+	}
+	traceFile := fs.Arg(0)
+	t, closer, err := openTrace(traceFile, *mm, *fromExecTrace)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	outf, err := os.Create(*outputFile)
+	if err != nil {
+		return err
+	}
+	defer outf.Close()
+	return pagetrace.WriteChromeTrace(outf, t)
+}
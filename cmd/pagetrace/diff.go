@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+type diffCmd struct {
+}
+
+func (c *diffCmd) Name() string {
+	return "diff"
+}
+
+func (c *diffCmd) Description() string {
+	return "compares two traces and reports or renders a static overlay image of the difference between their final memory states"
+}
+
+func (c *diffCmd) Run(args []string) error {
+	fs := subcommandFlags(c)
+	outputFile := fs.String("output", "", "if set, write a static PNG overlay (red=regressions, green=improvements, gray=unchanged), one row per memory granule, here instead of printing a summary; unlike view, this has no time axis and isn't served by a tile viewer")
+	memGranule := fs.Uint64("mem-granule", 1<<20, "size of each memory granule in bytes, used only with -output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("diff expected two arguments: a before trace and an after trace")
+	}
+	before, beforePeak, beforeFrag, err := runToEnd(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("simulating %s: %v", fs.Arg(0), err)
+	}
+	after, afterPeak, afterFrag, err := runToEnd(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("simulating %s: %v", fs.Arg(1), err)
+	}
+	d := before.Diff(after)
+	if *outputFile == "" {
+		fmt.Printf("peak allocated bytes: %d -> %d (%+d)\n", beforePeak, afterPeak, afterPeak-beforePeak)
+		fmt.Printf("fragmentation:    %.4f -> %.4f (%+.4f)\n", beforeFrag, afterFrag, afterFrag-beforeFrag)
+		fmt.Printf("newly allocated:  %d bytes\n", d.NewlyAllocatedBytes)
+		fmt.Printf("newly freed:      %d bytes\n", d.NewlyFreedBytes)
+		fmt.Printf("newly scavenged:  %d bytes\n", d.NewlyScavengedBytes)
+		fmt.Printf("unchanged:        %d bytes\n", d.UnchangedBytes)
+		fmt.Printf("total pages changed: %d\n", d.PagesChanged())
+		return nil
+	}
+	outf, err := os.Create(*outputFile)
+	if err != nil {
+		return err
+	}
+	defer outf.Close()
+	img := makeDiffImage(d, *memGranule)
+	return png.Encode(outf, img)
+}
+
+// runToEnd simulates traceFile to completion, returning the final
+// State, the peak number of allocated bytes observed along the way,
+// and the final fragmentation (see Simulator.Fragmentation).
+func runToEnd(traceFile string) (*pagetrace.State, int64, float64, error) {
+	f, err := os.Open(traceFile)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+	t, err := pagetrace.NewTrace(f)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	parser := pagetrace.NewParser(t)
+	var sim pagetrace.Simulator
+	var allocated, peak int64
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		sim.Feed(e)
+		switch e.Kind {
+		case pagetrace.EventAllocate:
+			allocated += int64(e.Size)
+		case pagetrace.EventFree:
+			allocated -= int64(e.Size)
+		}
+		if allocated > peak {
+			peak = allocated
+		}
+	}
+	return sim.Snapshot().Clone(), peak, sim.Fragmentation(), nil
+}
+
+// makeDiffImage renders a StateDiff as a single-column overlay with
+// one row per granule: red pixels mark newly allocated memory (a regression), green
+// pixels mark newly freed or scavenged memory (an improvement), and
+// gray pixels mark memory whose state didn't change.
+func makeDiffImage(d *pagetrace.StateDiff, memChunk uint64) *image.RGBA {
+	minAddr, maxAddr := d.MinAddr(), d.MaxAddr()
+	height := int(alignUp(maxAddr-minAddr, memChunk) / memChunk)
+	img := image.NewRGBA(image.Rect(0, 0, 1, height))
+	for i, y := minAddr, height-1; i < maxAddr; i, y = i+memChunk, y-1 {
+		img.SetRGBA(0, y, diffColor(d, i, memChunk))
+	}
+	return img
+}
+
+func diffColor(d *pagetrace.StateDiff, addr, memChunk uint64) color.RGBA {
+	var allocated, freed int
+	for a := alignDown(addr, memChunk); a < addr+memChunk && a < d.MaxAddr(); a += pagetrace.PageSize {
+		switch d.ClassAt(a) {
+		case pagetrace.NewlyAllocated:
+			allocated++
+		case pagetrace.NewlyFreed, pagetrace.NewlyScavenged:
+			freed++
+		}
+	}
+	switch {
+	case allocated > freed:
+		return color.RGBA{220, 50, 47, 255} // red: regression
+	case freed > allocated:
+		return color.RGBA{50, 180, 80, 255} // green: improvement
+	default:
+		return color.RGBA{128, 128, 128, 255} // gray: same
+	}
+}
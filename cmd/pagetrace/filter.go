@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+// eventSourceFactory returns a function that opens an eventSource for a
+// given trace slice, according to the execution trace correlation flags
+// passed to the view command. If execTraceFile is empty, the returned
+// factory just wraps a plain *pagetrace.Parser and the filter flags are
+// ignored.
+//
+// If execTraceFile is set, it's indexed once here rather than by the
+// returned factory, so that repeated calls to the factory — as made by
+// view's tile tree on every cache-miss tile rebuild — share the same
+// ExecTraceIndex instead of each re-scanning the whole execution trace
+// from scratch.
+func eventSourceFactory(execTraceFile string, goroutine uint64, gcphase, callsite string) (func(*pagetrace.Trace) (eventSource, error), error) {
+	if execTraceFile == "" {
+		return func(t *pagetrace.Trace) (eventSource, error) {
+			return pagetrace.NewParser(t), nil
+		}, nil
+	}
+	var phase pagetrace.GCPhase
+	var wantPhase bool
+	if gcphase != "" {
+		p, ok := parseGCPhase(gcphase)
+		if !ok {
+			return nil, fmt.Errorf("unknown -gcphase %q", gcphase)
+		}
+		phase, wantPhase = p, true
+	}
+	f, err := os.Open(execTraceFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idx, err := pagetrace.IndexExecTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("indexing execution trace: %v", err)
+	}
+	return func(t *pagetrace.Trace) (eventSource, error) {
+		ap := pagetrace.NewAnnotatedParserFromIndex(t, idx)
+		return &filteredSource{
+			ap:        ap,
+			goroutine: goroutine,
+			gcphase:   phase,
+			wantPhase: wantPhase,
+			callsite:  callsite,
+		}, nil
+	}, nil
+}
+
+func parseGCPhase(s string) (pagetrace.GCPhase, bool) {
+	for _, p := range []pagetrace.GCPhase{
+		pagetrace.GCOff,
+		pagetrace.GCMarkAssist,
+		pagetrace.GCMark,
+		pagetrace.GCMarkTermination,
+		pagetrace.GCSweep,
+	} {
+		if p.String() == s {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// filteredSource adapts a *pagetrace.AnnotatedParser into an eventSource
+// that skips events not matching the requested goroutine, GC phase, or
+// call site.
+type filteredSource struct {
+	ap        *pagetrace.AnnotatedParser
+	goroutine uint64
+	gcphase   pagetrace.GCPhase
+	wantPhase bool
+	callsite  string
+}
+
+func (s *filteredSource) Next() (pagetrace.Event, error) {
+	for {
+		ae, err := s.ap.Next()
+		if err != nil {
+			return pagetrace.Event{}, err
+		}
+		if s.goroutine != 0 && ae.GoID != s.goroutine {
+			continue
+		}
+		if s.wantPhase && ae.GCPhase != s.gcphase {
+			continue
+		}
+		if s.callsite != "" && !containsCallsite(ae.Stack, s.callsite) {
+			continue
+		}
+		return ae.Event, nil
+	}
+}
+
+func containsCallsite(stack []pagetrace.Frame, callsite string) bool {
+	for _, f := range stack {
+		if strings.Contains(f.Func, callsite) {
+			return true
+		}
+	}
+	return false
+}
@@ -29,6 +29,10 @@ func (c *imageCmd) Run(args []string) error {
 	memGranule := fs.Uint64("mem-granule", 0, "size of each memory granule in bytes")
 	memGranuleAlign := fs.Uint64("mem-granule-align", 1, "address alignment of each granule")
 	outputFile := fs.String("output", "", "where to write the png image")
+	layout := fs.String("layout", "linear", "heatmap layout: \"linear\" (address on a single Y axis) or \"hilbert\" (one Hilbert-curve square tile per time slice, preserving address locality in both dimensions)")
+	mm := fs.Bool("mmap", false, "memory-map the trace file instead of reading it through the file descriptor")
+	fromExecTrace := fs.Bool("from-exec-trace", false, "treat the trace as an execution trace collected with GODEBUG=traceallocfree=1, rather than a standalone page trace")
+	stream := fs.Bool("stream", false, "parse the trace with two forward-only streaming passes instead of one random-access pass, so traces larger than RAM can be processed")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -44,30 +48,47 @@ func (c *imageCmd) Run(args []string) error {
 	if *outputFile == "" {
 		return fmt.Errorf("must specify -output")
 	}
-	traceFile := fs.Arg(0)
-	f, err := os.Open(traceFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	t, err := pagetrace.NewTrace(f)
-	if err != nil {
-		return err
+	if *stream && *fromExecTrace {
+		return fmt.Errorf("-stream is not supported together with -from-exec-trace")
 	}
-	snaps, _, err := takeSnapshots(t, *timeGranule, nil)
-	if err != nil {
-		return err
+	traceFile := fs.Arg(0)
+
+	var snaps []*pagetrace.State
+	var minAddr, maxAddr uint64
+	if *stream {
+		var err error
+		snaps, minAddr, maxAddr, err = takeSnapshotsStreaming(traceFile, *timeGranule)
+		if err != nil {
+			return err
+		}
+	} else {
+		t, closer, err := openTrace(traceFile, *mm, *fromExecTrace)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+		snaps, _, err = takeSnapshots(pagetrace.NewParser(t), t.TimeStart(), t.TimeEnd(), *timeGranule, nil)
+		if err != nil {
+			return err
+		}
+		minAddr, maxAddr = t.MinAddr(), t.MaxAddr()
 	}
 
 	memChunk := *memGranule
 	memAlign := *memGranuleAlign
 
-	minAddr, maxAddr := t.MinAddr(), t.MaxAddr()
-
 	minAddr = alignDown(minAddr, memAlign)
 	maxAddr = alignUp(maxAddr, memAlign)
 
-	img := makeImage(snaps, minAddr, maxAddr, memChunk)
+	var img *image.RGBA
+	switch *layout {
+	case "linear":
+		img = makeImage(snaps, minAddr, maxAddr, memChunk)
+	case "hilbert":
+		img = makeHilbertImage(snaps, minAddr, maxAddr, memChunk)
+	default:
+		return fmt.Errorf("unknown -layout %q", *layout)
+	}
 
 	outf, err := os.Create(*outputFile)
 	if err != nil {
@@ -100,6 +121,79 @@ func makeImage(snaps []*pagetrace.State, minAddr, maxAddr, memChunk uint64) *ima
 	return img
 }
 
+// makeHilbertImage is like makeImage, but instead of laying memory out
+// linearly on the Y axis, it maps each time slice's occupancy values
+// onto a square tile via a Hilbert curve, then stitches the tiles
+// horizontally into a filmstrip. Because the Hilbert curve keeps
+// curve-adjacent chunks spatially adjacent, this preserves address
+// locality in both dimensions of a tile instead of just one, making
+// arena boundaries and fragmentation patterns visible as 2D shapes
+// instead of thin horizontal bands.
+func makeHilbertImage(snaps []*pagetrace.State, minAddr, maxAddr, memChunk uint64) *image.RGBA {
+	// Align up maxAddr to memChunk, as makeImage does.
+	maxAddr = minAddr + (((maxAddr-minAddr)+memChunk-1)/memChunk)*memChunk
+	numChunks := int((maxAddr - minAddr) / memChunk)
+	order := 0
+	for (1 << uint(2*order)) < numChunks {
+		order++
+	}
+	side := 1 << uint(order)
+
+	img := image.NewRGBA(image.Rect(0, 0, side*len(snaps), side))
+	for tile, snap := range snaps {
+		xOff := tile * side
+		for i := 0; i < numChunks; i++ {
+			x, y := hilbertD2XY(order, i)
+			var c color.RGBA
+			if snap == nil {
+				c = color.RGBA{0, 0, 0, 255}
+			} else {
+				addr := minAddr + uint64(i)*memChunk
+				occupancy := float64(snap.Allocated(addr, memChunk)) / float64(memChunk)
+				if occupancy == 0 {
+					c = color.RGBA{0, 0, 0, 255}
+				} else {
+					c = viridis.Map(occupancy).(color.RGBA)
+				}
+			}
+			// Flip y so chunk 0 starts at the bottom, matching makeImage's
+			// bottom-up address axis.
+			img.SetRGBA(xOff+x, side-1-y, c)
+		}
+	}
+	return img
+}
+
+// hilbertD2XY converts d, a distance along a Hilbert curve of the
+// given order (a curve over a 2^order x 2^order grid), into (x, y)
+// coordinates. This is the standard iterative d2xy construction; see
+// https://en.wikipedia.org/wiki/Hilbert_curve#Applications_and_mapping_algorithms.
+func hilbertD2XY(order, d int) (x, y int) {
+	t := d
+	for s := 1; s < (1 << uint(order)); s *= 2 {
+		rx := 1 & (t / 2)
+		ry := 1 & (t ^ rx)
+		x, y = hilbertRotate(s, x, y, rx, ry)
+		x += s * rx
+		y += s * ry
+		t /= 4
+	}
+	return x, y
+}
+
+// hilbertRotate rotates/reflects the quadrant (x, y) within an n x n
+// block so that successive quadrants connect into a single curve.
+func hilbertRotate(n, x, y, rx, ry int) (int, int) {
+	if ry == 0 {
+		if rx == 1 {
+			x = n - 1 - x
+			y = n - 1 - y
+		}
+		x, y = y, x
+	}
+	return x, y
+}
+
 func alignDown(v, align uint64) uint64 {
 	return (v / align) * align
 }
@@ -108,15 +202,21 @@ func alignUp(v, align uint64) uint64 {
 	return ((v + align - 1) / align) * align
 }
 
-func takeSnapshots(t *pagetrace.Trace, timeGranule time.Duration, start *pagetrace.State) ([]*pagetrace.State, *pagetrace.State, error) {
-	parser := pagetrace.NewParser(t)
+// eventSource yields a stream of page trace events. It's implemented by
+// *pagetrace.Parser as well as filtered event sources derived from an
+// *pagetrace.AnnotatedParser.
+type eventSource interface {
+	Next() (pagetrace.Event, error)
+}
+
+func takeSnapshots(events eventSource, timeStart, timeEnd, timeGranule time.Duration, start *pagetrace.State) ([]*pagetrace.State, *pagetrace.State, error) {
 	var snaps []*pagetrace.State
 	var sim pagetrace.Simulator
 	if start != nil {
 		sim.SetState(start)
 	}
 	snaps = append(snaps, start)
-	now := t.TimeStart()
+	now := timeStart
 	lastSnapTime := now
 	for {
 		for now-lastSnapTime > timeGranule {
@@ -124,7 +224,7 @@ func takeSnapshots(t *pagetrace.Trace, timeGranule time.Duration, start *pagetra
 			snaps = append(snaps, sim.Snapshot().Clone())
 			lastSnapTime += timeGranule
 		}
-		e, err := parser.Next()
+		e, err := events.Next()
 		if err == io.EOF {
 			break
 		}
@@ -135,8 +235,52 @@ func takeSnapshots(t *pagetrace.Trace, timeGranule time.Duration, start *pagetra
 		now = e.Time
 	}
 	last := sim.Snapshot().Clone()
-	for t.TimeStart()+time.Duration(len(snaps))*timeGranule < t.TimeEnd() {
+	for timeStart+time.Duration(len(snaps))*timeGranule < timeEnd {
 		snaps = append(snaps, last)
 	}
 	return snaps, last, nil
 }
+
+// takeSnapshotsStreaming builds the same per-granule snapshots as
+// takeSnapshots, but for traces too large to read with openTrace's
+// random-access pass: it reads traceFile with a pagetrace.StreamingParser
+// in two forward-only passes instead, one to learn the trace's
+// address range and duration, and a second to actually feed events
+// into the Simulator.
+func takeSnapshotsStreaming(traceFile string, timeGranule time.Duration) (snaps []*pagetrace.State, minAddr, maxAddr uint64, err error) {
+	bounds, err := os.Open(traceFile)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	boundsParser, err := pagetrace.NewStreamingParser(bounds)
+	if err != nil {
+		bounds.Close()
+		return nil, 0, 0, err
+	}
+	for {
+		if _, err := boundsParser.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			bounds.Close()
+			return nil, 0, 0, err
+		}
+	}
+	timeEnd := boundsParser.TimeEnd()
+	minAddr, maxAddr = boundsParser.MinAddr(), boundsParser.MaxAddr()
+	bounds.Close()
+
+	f, err := os.Open(traceFile)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+	p, err := pagetrace.NewStreamingParser(f)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	snaps, _, err = takeSnapshots(p, 0, timeEnd, timeGranule, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return snaps, minAddr, maxAddr, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+// TestHilbertD2XYBijection checks that hilbertD2XY maps every
+// distance along a Hilbert curve of a given order to a distinct
+// coordinate inside the curve's grid, i.e. that it's a bijection
+// between [0, side*side) and the side x side grid.
+func TestHilbertD2XYBijection(t *testing.T) {
+	for order := 1; order <= 6; order++ {
+		side := 1 << uint(order)
+		seen := make(map[[2]int]bool)
+		for d := 0; d < side*side; d++ {
+			x, y := hilbertD2XY(order, d)
+			if x < 0 || x >= side || y < 0 || y >= side {
+				t.Fatalf("order %d: hilbertD2XY(%d) = (%d, %d), out of [0, %d) grid", order, d, x, y, side)
+			}
+			p := [2]int{x, y}
+			if seen[p] {
+				t.Fatalf("order %d: hilbertD2XY(%d) = (%d, %d) collides with an earlier distance", order, d, x, y)
+			}
+			seen[p] = true
+		}
+	}
+}
+
+// TestHilbertD2XYAdjacent checks the curve's defining property: points
+// at consecutive distances are always adjacent on the grid.
+func TestHilbertD2XYAdjacent(t *testing.T) {
+	const order = 4
+	side := 1 << uint(order)
+	px, py := hilbertD2XY(order, 0)
+	for d := 1; d < side*side; d++ {
+		x, y := hilbertD2XY(order, d)
+		dx, dy := x-px, y-py
+		if dx < 0 {
+			dx = -dx
+		}
+		if dy < 0 {
+			dy = -dy
+		}
+		if dx+dy != 1 {
+			t.Fatalf("hilbertD2XY(%d)=(%d,%d) isn't adjacent to hilbertD2XY(%d)=(%d,%d)", d, x, y, d-1, px, py)
+		}
+		px, py = x, y
+	}
+}
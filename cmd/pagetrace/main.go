@@ -3,8 +3,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+
+	"golang.org/x/debug/internal/pagetrace"
 )
 
 type subcmd struct {
@@ -41,6 +44,61 @@ func run() (bool, error) {
 	return true, fmt.Errorf("no command specified")
 }
 
+// openTraceFile opens traceFile for use as the io.ReaderAt backing a
+// pagetrace.Trace. If useMmap is true, the file is memory-mapped via
+// pagetrace.OpenMmap instead of being opened as a regular *os.File;
+// this avoids copying the whole trace through read(2) and is worth
+// enabling for traces too big to comfortably read into the page
+// cache. The returned io.Closer must be closed once the Trace built
+// from the reader is no longer needed.
+func openTraceFile(traceFile string, useMmap bool) (io.ReaderAt, io.Closer, error) {
+	if useMmap {
+		r, err := pagetrace.OpenMmap(traceFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r, nil
+	}
+	f, err := os.Open(traceFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f, nil
+}
+
+// openTrace opens traceFile and parses it into a *pagetrace.Trace,
+// using openTraceFile to obtain the underlying reader. If
+// fromExecTrace is true, traceFile is instead treated as an execution
+// trace collected with GODEBUG=traceallocfree=1, and is converted via
+// pagetrace.NewTraceFromExecTrace; this is how the pagetrace tools
+// keep working against Go 1.23+ traces, which no longer have a
+// standalone page trace format. The returned io.Closer must be closed
+// once the Trace is no longer needed.
+func openTrace(traceFile string, useMmap, fromExecTrace bool) (*pagetrace.Trace, io.Closer, error) {
+	if fromExecTrace {
+		f, err := os.Open(traceFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		t, err := pagetrace.NewTraceFromExecTrace(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return t, f, nil
+	}
+	r, closer, err := openTraceFile(traceFile, useMmap)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := pagetrace.NewTrace(r)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return t, closer, nil
+}
+
 func subcommandFlags(s subcommand) *flag.FlagSet {
 	name := s.Name()
 	desc := s.Description()
@@ -80,6 +138,11 @@ func init() {
 	register(&printCmd{})
 	register(&imageCmd{})
 	register(&viewCmd{})
+	register(&diffCmd{})
+	register(&pprofCmd{})
+	register(&mmuCmd{})
+	register(&chrometraceCmd{})
+	register(&statsCmd{})
 }
 
 func main() {
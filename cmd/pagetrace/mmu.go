@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+type mmuCmd struct {
+}
+
+func (c *mmuCmd) Name() string {
+	return "mmu"
+}
+
+func (c *mmuCmd) Description() string {
+	return "serves an interactive plot of memory utilization vs. window size, in the style of go tool trace's MMU page"
+}
+
+func (c *mmuCmd) Run(args []string) error {
+	fs := subcommandFlags(c)
+	host := fs.String("http", "localhost:8081", "host and port combination for the web server")
+	mm := fs.Bool("mmap", false, "memory-map the trace file instead of reading it through the file descriptor")
+	fromExecTrace := fs.Bool("from-exec-trace", false, "treat the trace as an execution trace collected with GODEBUG=traceallocfree=1, rather than a standalone page trace")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("mmu expected one argument: a trace")
+	}
+	traceFile := fs.Arg(0)
+	t, closer, err := openTrace(traceFile, *mm, *fromExecTrace)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, mmuPage)
+	})
+	http.HandleFunc("/mmu", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != "" {
+			log.Print("bad request method ", r.Method)
+			http.Error(w, "must be a GET request", http.StatusBadRequest)
+			return
+		}
+		log.Print(r.URL.String())
+		points := 50
+		if v := r.URL.Query().Get("points"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("malformed points: %v", err), http.StatusBadRequest)
+				return
+			}
+			points = n
+		}
+		curve, err := mmuCurve(t, points)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(curve); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	})
+	log.Print("Server ready!")
+	return http.ListenAndServe(*host, nil)
+}
+
+// mmuPoint is a single sample of the memory-utilization-vs-window-size
+// curve: for windows of the given size, how memory utilization was
+// distributed across the trace.
+type mmuPoint struct {
+	Window time.Duration
+	P50    float64
+	P90    float64
+	P99    float64
+	Worst  float64
+}
+
+// mmuCurve samples t.MemoryUtilization at points window sizes
+// log-spaced between a microsecond and the trace's full duration,
+// mirroring how go tool trace sweeps window size for its MMU chart.
+//
+// It computes every window size from a single call to
+// MemoryUtilizationWindows rather than calling MemoryUtilization once
+// per window size, since the latter would replay the whole trace up
+// to points times per request.
+func mmuCurve(t *pagetrace.Trace, points int) ([]mmuPoint, error) {
+	if points <= 0 {
+		points = 1
+	}
+	minWindow := time.Microsecond
+	maxWindow := t.Duration()
+	if maxWindow <= minWindow {
+		maxWindow = minWindow
+	}
+	logMin, logMax := math.Log(float64(minWindow)), math.Log(float64(maxWindow))
+
+	windows := make([]time.Duration, points)
+	for i := range windows {
+		denom := points - 1
+		if denom == 0 {
+			denom = 1
+		}
+		frac := float64(i) / float64(denom)
+		window := time.Duration(math.Exp(logMin + frac*(logMax-logMin)))
+		if window <= 0 {
+			window = minWindow
+		}
+		windows[i] = window
+	}
+
+	muds, err := t.MemoryUtilizationWindows(windows)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := make([]mmuPoint, len(windows))
+	for i, mud := range muds {
+		_, worst := mud.WorstWindow()
+		curve[i] = mmuPoint{
+			Window: windows[i],
+			P50:    mud.Quantile(0.5),
+			P90:    mud.Quantile(0.9),
+			P99:    mud.Quantile(0.99),
+			Worst:  worst,
+		}
+	}
+	return curve, nil
+}
+
+// mmuPage is a minimal standalone page plotting the MMU curve served
+// at /mmu; it's deliberately simple rather than sharing view's tile
+// viewer, since it only ever needs to draw one small line chart.
+const mmuPage = `<!DOCTYPE html>
+<html>
+<head><title>pagetrace mmu</title></head>
+<body>
+<canvas id="chart" width="900" height="500"></canvas>
+<script>
+fetch('/mmu').then(r => r.json()).then(points => {
+	const c = document.getElementById('chart');
+	const ctx = c.getContext('2d');
+	const series = ['P50', 'P90', 'P99', 'Worst'];
+	const colors = {P50: '#4c78a8', P90: '#f58518', P99: '#e45756', Worst: '#000000'};
+	const xs = points.map(p => Math.log(p.Window));
+	const xmin = Math.min(...xs), xmax = Math.max(...xs);
+	const px = x => 40 + (x - xmin) / (xmax - xmin || 1) * (c.width - 60);
+	const py = y => c.height - 30 - y * (c.height - 60);
+	ctx.strokeStyle = '#ccc';
+	ctx.strokeRect(40, 10, c.width - 80, c.height - 60);
+	for (const name of series) {
+		ctx.strokeStyle = colors[name];
+		ctx.beginPath();
+		points.forEach((p, i) => {
+			const x = px(Math.log(p.Window)), y = py(p[name]);
+			if (i === 0) ctx.moveTo(x, y); else ctx.lineTo(x, y);
+		});
+		ctx.stroke();
+	}
+});
+</script>
+</body>
+</html>
+`
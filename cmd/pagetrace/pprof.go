@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+type pprofCmd struct {
+}
+
+func (c *pprofCmd) Name() string {
+	return "pprof"
+}
+
+func (c *pprofCmd) Description() string {
+	return "exports the trace as a pprof profile.proto for use with go tool pprof"
+}
+
+func (c *pprofCmd) Run(args []string) error {
+	fs := subcommandFlags(c)
+	mode := fs.String("mode", "snapshot", "what to export: \"snapshot\" (state at -time), \"peak\" (state at peak allocated bytes), or \"integrated\" (time-weighted over the whole trace)")
+	at := fs.Duration("time", -1, "for -mode=snapshot, the point in the trace to snapshot; defaults to the end of the trace")
+	execTraceFile := fs.String("exec-trace", "", "execution trace to correlate against the page trace, attaching allocating stacks to samples (used only by -mode=snapshot; ignored for -mode=peak and -mode=integrated)")
+	outputFile := fs.String("output", "", "where to write the pprof profile")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("pprof expected one argument: a trace")
+	}
+	if *outputFile == "" {
+		return fmt.Errorf("must specify -output")
+	}
+	traceFile := fs.Arg(0)
+	f, err := os.Open(traceFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	t, err := pagetrace.NewTrace(f)
+	if err != nil {
+		return err
+	}
+
+	outf, err := os.Create(*outputFile)
+	if err != nil {
+		return err
+	}
+	defer outf.Close()
+
+	switch *mode {
+	case "snapshot":
+		cutoff := *at
+		if cutoff < 0 {
+			cutoff = t.TimeEnd()
+		}
+		return writeSnapshotProfile(outf, t, cutoff, *execTraceFile)
+	case "peak":
+		return writePeakProfile(outf, t, *execTraceFile)
+	case "integrated":
+		return writeIntegratedProfile(outf, t)
+	default:
+		return fmt.Errorf("unknown -mode %q", *mode)
+	}
+}
+
+func writeSnapshotProfile(w io.Writer, t *pagetrace.Trace, cutoff time.Duration, execTraceFile string) error {
+	slice := t.Slice(t.TimeStart(), cutoff)
+	if execTraceFile == "" {
+		snaps, _, err := takeSnapshots(pagetrace.NewParser(slice), slice.TimeStart(), slice.TimeEnd(), slice.Duration(), nil)
+		if err != nil {
+			return err
+		}
+		return lastState(snaps).WriteProfile(w, "inuse_space")
+	}
+	ef, err := os.Open(execTraceFile)
+	if err != nil {
+		return err
+	}
+	defer ef.Close()
+	ap := pagetrace.NewAnnotatedParser(slice, ef)
+	s, stacks, err := ap.AllocationStacks()
+	if err != nil {
+		return err
+	}
+	return pagetrace.WriteAnnotatedProfile(w, s, "inuse_space", stacks)
+}
+
+func writePeakProfile(w io.Writer, t *pagetrace.Trace, execTraceFile string) error {
+	parser := pagetrace.NewParser(t)
+	var sim pagetrace.Simulator
+	var allocated, peak int64
+	var peakState *pagetrace.State
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sim.Feed(e)
+		switch e.Kind {
+		case pagetrace.EventAllocate:
+			allocated += int64(e.Size)
+		case pagetrace.EventFree:
+			allocated -= int64(e.Size)
+		}
+		if allocated > peak {
+			peak = allocated
+			peakState = sim.Snapshot().Clone()
+		}
+	}
+	if peakState == nil {
+		peakState = sim.Snapshot().Clone()
+	}
+	// Stack attribution for the peak snapshot would require re-running
+	// an AnnotatedParser up to the peak's timestamp; since that
+	// timestamp is only known after the fact, we only support
+	// unannotated peak profiles for now.
+	_ = execTraceFile
+	return peakState.WriteProfile(w, "inuse_space")
+}
+
+// writeIntegratedProfile emits a profile whose sample value for each
+// page is the number of nanoseconds that page was allocated over the
+// course of the whole trace, which approximates the memory-bandwidth
+// cost of that page to the application.
+func writeIntegratedProfile(w io.Writer, t *pagetrace.Trace) error {
+	parser := pagetrace.NewParser(t)
+	allocSince := make(map[uint64]time.Duration)
+	nsAllocated := make(map[uint64]int64)
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch e.Kind {
+		case pagetrace.EventAllocate:
+			for a := e.Base; a < e.Base+e.Size; a += pagetrace.PageSize {
+				allocSince[a] = e.Time
+			}
+		case pagetrace.EventFree:
+			for a := e.Base; a < e.Base+e.Size; a += pagetrace.PageSize {
+				if since, ok := allocSince[a]; ok {
+					nsAllocated[a] += int64(e.Time - since)
+					delete(allocSince, a)
+				}
+			}
+		}
+	}
+	for a, since := range allocSince {
+		nsAllocated[a] += int64(t.TimeEnd() - since)
+	}
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "time_allocated", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "time_allocated", Unit: "nanoseconds"},
+		Period:     1,
+	}
+	for addr, ns := range nsAllocated {
+		if ns == 0 {
+			continue
+		}
+		p.Sample = append(p.Sample, &profile.Sample{
+			Value: []int64{ns},
+			NumLabel: map[string][]int64{
+				"base": {int64(addr)},
+				"size": {int64(pagetrace.PageSize)},
+			},
+			NumUnit: map[string][]string{
+				"base": {"bytes"},
+				"size": {"bytes"},
+			},
+		})
+	}
+	return p.Write(w)
+}
+
+func lastState(snaps []*pagetrace.State) *pagetrace.State {
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if snaps[i] != nil {
+			return snaps[i]
+		}
+	}
+	return &pagetrace.State{}
+}
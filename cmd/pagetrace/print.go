@@ -21,6 +21,8 @@ func (p *printCmd) Description() string {
 
 func (p *printCmd) Run(args []string) error {
 	fs := subcommandFlags(p)
+	mm := fs.Bool("mmap", false, "memory-map the trace file instead of reading it through the file descriptor")
+	fromExecTrace := fs.Bool("from-exec-trace", false, "treat the trace as an execution trace collected with GODEBUG=traceallocfree=1, rather than a standalone page trace")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -28,15 +30,11 @@ func (p *printCmd) Run(args []string) error {
 		return fmt.Errorf("print expected one argument: a trace")
 	}
 	traceFile := fs.Arg(0)
-	f, err := os.Open(traceFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	t, err := pagetrace.NewTrace(f)
+	t, closer, err := openTrace(traceFile, *mm, *fromExecTrace)
 	if err != nil {
 		return err
 	}
+	defer closer.Close()
 	parser := pagetrace.NewParser(t)
 	var sim pagetrace.Simulator
 	for {
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+type statsCmd struct {
+}
+
+func (c *statsCmd) Name() string {
+	return "stats"
+}
+
+func (c *statsCmd) Description() string {
+	return "emits a CSV time series of fragmentation and scavenger-effectiveness metrics"
+}
+
+func (c *statsCmd) Run(args []string) error {
+	fs := subcommandFlags(c)
+	granule := fs.Duration("time-granule", 0, "size of each time granule in the output series")
+	outputFile := fs.String("output", "", "where to write the CSV; defaults to stdout")
+	mm := fs.Bool("mmap", false, "memory-map the trace file instead of reading it through the file descriptor")
+	fromExecTrace := fs.Bool("from-exec-trace", false, "treat the trace as an execution trace collected with GODEBUG=traceallocfree=1, rather than a standalone page trace")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("stats expected one argument: a trace")
+	}
+	if *granule == 0 {
+		return fmt.Errorf("must specify -time-granule")
+	}
+	traceFile := fs.Arg(0)
+	t, closer, err := openTrace(traceFile, *mm, *fromExecTrace)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	out := io.Writer(os.Stdout)
+	if *outputFile != "" {
+		outf, err := os.Create(*outputFile)
+		if err != nil {
+			return err
+		}
+		defer outf.Close()
+		out = outf
+	}
+	return writeStatsCSV(out, t, *granule)
+}
+
+// writeStatsCSV walks t's events once, emitting a row of fragmentation
+// and scavenger-effectiveness metrics to w every granule of trace
+// time, in the style of takeSnapshots' time-granule loop.
+func writeStatsCSV(w io.Writer, t *pagetrace.Trace, granule time.Duration) error {
+	parser := pagetrace.NewParser(t)
+	var sim pagetrace.Simulator
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time_ns", "fragmentation", "scavenged_ratio", "free_run_histogram"}); err != nil {
+		return err
+	}
+	writeRow := func(at time.Duration) error {
+		hist := sim.FreeRunHistogram()
+		bucketStrs := make([]string, len(hist))
+		for i, n := range hist {
+			bucketStrs[i] = strconv.Itoa(n)
+		}
+		return cw.Write([]string{
+			strconv.FormatInt(int64(at), 10),
+			strconv.FormatFloat(sim.Fragmentation(), 'f', -1, 64),
+			strconv.FormatFloat(sim.ScavengedRatio(), 'f', -1, 64),
+			strings.Join(bucketStrs, "|"),
+		})
+	}
+
+	now := t.TimeStart()
+	lastRow := now
+	for {
+		for now-lastRow > granule {
+			if err := writeRow(lastRow); err != nil {
+				return err
+			}
+			lastRow += granule
+		}
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sim.Feed(e)
+		now = e.Time
+	}
+	if err := writeRow(now); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/debug/internal/pagetrace"
+)
+
+// TileStore caches the individual State snapshots that make up the
+// tiles in a traceTree, so that a traceTree never needs to hold every
+// generated snapshot in memory for the lifetime of the process.
+//
+// level identifies the depth of the tile the snapshot belongs to, t is
+// the snapshot's exact timestamp, and a is the trace's base address.
+// A State always covers the trace's entire observed address range, so
+// a is constant across all tiles; it's part of the key only so a
+// TileStore could, in principle, be shared across traces.
+type TileStore interface {
+	Get(level int, t time.Duration, a uint64) (*pagetrace.State, bool)
+	Put(level int, t time.Duration, a uint64, s *pagetrace.State)
+}
+
+// tileKey identifies a single cached State.
+type tileKey struct {
+	level int
+	t     time.Duration
+	a     uint64
+}
+
+// memTileStore is a TileStore backed by an in-memory LRU cache with a
+// configurable byte budget.
+type memTileStore struct {
+	mu     sync.Mutex
+	budget int64
+	used   int64
+	lru    *list.List // of *memTileEntry, front = most recently used
+	items  map[tileKey]*list.Element
+}
+
+type memTileEntry struct {
+	key   tileKey
+	state *pagetrace.State
+}
+
+// newMemTileStore creates an in-memory TileStore that evicts the
+// least-recently-used snapshot once its cached snapshots exceed
+// budgetBytes.
+func newMemTileStore(budgetBytes int64) *memTileStore {
+	return &memTileStore{
+		budget: budgetBytes,
+		lru:    list.New(),
+		items:  make(map[tileKey]*list.Element),
+	}
+}
+
+func (m *memTileStore) Get(level int, t time.Duration, a uint64) (*pagetrace.State, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := tileKey{level, t, a}
+	e, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(e)
+	return e.Value.(*memTileEntry).state, true
+}
+
+func (m *memTileStore) Put(level int, t time.Duration, a uint64, s *pagetrace.State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := tileKey{level, t, a}
+	if e, ok := m.items[key]; ok {
+		m.lru.MoveToFront(e)
+		e.Value.(*memTileEntry).state = s
+		return
+	}
+	e := m.lru.PushFront(&memTileEntry{key: key, state: s})
+	m.items[key] = e
+	m.used += int64(s.ByteSize())
+	for m.used > m.budget && m.lru.Len() > 1 {
+		back := m.lru.Back()
+		ent := back.Value.(*memTileEntry)
+		m.lru.Remove(back)
+		delete(m.items, ent.key)
+		m.used -= int64(ent.state.ByteSize())
+	}
+}
+
+// diskTileStore is a TileStore backed by compressed bitmap files under
+// a cache directory, keyed by (level, t, a). It's meant to be layered
+// underneath a memTileStore via newTieredTileStore so that repeat
+// `view` invocations of the same trace can skip replaying events
+// entirely.
+type diskTileStore struct {
+	dir string
+}
+
+func newDiskTileStore(dir string) (*diskTileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &diskTileStore{dir: dir}, nil
+}
+
+func (d *diskTileStore) path(level int, t time.Duration, a uint64) string {
+	return filepath.Join(d.dir, fmt.Sprintf("L%d-T%d-A%x.tile", level, int64(t), a))
+}
+
+func (d *diskTileStore) Get(level int, t time.Duration, a uint64) (*pagetrace.State, bool) {
+	f, err := os.Open(d.path(level, t, a))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	s, err := pagetrace.ReadState(f)
+	if err != nil {
+		return nil, false
+	}
+	return s, true
+}
+
+func (d *diskTileStore) Put(level int, t time.Duration, a uint64, s *pagetrace.State) {
+	f, err := os.Create(d.path(level, t, a))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	s.WriteTo(f)
+}
+
+// tieredTileStore checks a fast in-memory cache before falling back to
+// a slower, larger backing store (typically disk-backed), promoting
+// hits from the backing store back into the memory tier.
+type tieredTileStore struct {
+	mem     *memTileStore
+	backing TileStore
+}
+
+func newTieredTileStore(mem *memTileStore, backing TileStore) *tieredTileStore {
+	return &tieredTileStore{mem: mem, backing: backing}
+}
+
+func (t *tieredTileStore) Get(level int, tm time.Duration, a uint64) (*pagetrace.State, bool) {
+	if s, ok := t.mem.Get(level, tm, a); ok {
+		return s, true
+	}
+	if t.backing == nil {
+		return nil, false
+	}
+	s, ok := t.backing.Get(level, tm, a)
+	if ok {
+		t.mem.Put(level, tm, a, s)
+	}
+	return s, ok
+}
+
+func (t *tieredTileStore) Put(level int, tm time.Duration, a uint64, s *pagetrace.State) {
+	t.mem.Put(level, tm, a, s)
+	if t.backing != nil {
+		t.backing.Put(level, tm, a, s)
+	}
+}
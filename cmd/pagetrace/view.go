@@ -32,6 +32,12 @@ func (c *viewCmd) Description() string {
 func (c *viewCmd) Run(args []string) error {
 	fs := subcommandFlags(c)
 	host := fs.String("http", "localhost:8080", "host and port combination for the web server")
+	execTraceFile := fs.String("exec-trace", "", "execution trace to correlate against the page trace, enabling -goroutine, -gcphase, and -callsite filters")
+	goroutine := fs.Uint64("goroutine", 0, "if -exec-trace is set and non-zero, only show pages touched by this goroutine")
+	gcphase := fs.String("gcphase", "", "if -exec-trace is set, only show pages touched while this GC phase was active (off, mark assist, mark, mark termination, sweep)")
+	callsite := fs.String("callsite", "", "if -exec-trace is set, only show pages touched by a stack containing this function name")
+	cacheBytes := fs.Int64("cache-bytes", 1<<30, "budget, in bytes, for the in-memory tile cache")
+	cacheDir := fs.String("cache-dir", "", "if set, persist tiles as compressed bitmaps under this directory so future view invocations of the same trace start instantly")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -48,7 +54,20 @@ func (c *viewCmd) Run(args []string) error {
 	if err != nil {
 		return err
 	}
-	tt, err := makeTraceTree(t)
+	newSource, err := eventSourceFactory(*execTraceFile, *goroutine, *gcphase, *callsite)
+	if err != nil {
+		return err
+	}
+	mem := newMemTileStore(*cacheBytes)
+	var store TileStore = mem
+	if *cacheDir != "" {
+		disk, err := newDiskTileStore(*cacheDir)
+		if err != nil {
+			return err
+		}
+		store = newTieredTileStore(mem, disk)
+	}
+	tt, err := makeTraceTree(t, newSource, store)
 	if err != nil {
 		return err
 	}
@@ -164,13 +183,30 @@ type traceTree struct {
 	height          int
 	maxTileDuration time.Duration
 	maxTileMemChunk uint64
+	newSource       func(*pagetrace.Trace) (eventSource, error)
+	store           TileStore
 }
 
+// snapNode is one tile's worth of structure in a traceTree. It doesn't
+// hold onto its tileSize snapshots directly -- those live in the
+// traceTree's TileStore, which may evict them at any time -- so that a
+// traceTree's memory footprint is bounded by the store's budget rather
+// than by the number of tiles a view session has ever touched.
 type snapNode struct {
 	minTime, maxTime time.Duration
-	snaps            [tileSize]*pagetrace.State
-	children         [magFactor]*snapNode
-	childLocks       [magFactor]sync.Mutex
+	level            int
+	minAddr          uint64
+
+	// seedNode and seedIdx identify where to find the State to seed
+	// a simulation with if this node's snapshots need to be rebuilt
+	// after an eviction. seedNode is nil for the very first node in
+	// the trace, which simulates from an empty state.
+	seedNode *snapNode
+	seedIdx  int
+
+	buildMu    sync.Mutex
+	children   [magFactor]*snapNode
+	childLocks [magFactor]sync.Mutex
 }
 
 const (
@@ -182,7 +218,11 @@ const (
 	minTileMemChunk = uint64(minMemChunk * tileSize)
 )
 
-func makeTraceTree(t *pagetrace.Trace) (*traceTree, error) {
+// makeTraceTree builds a traceTree over t. store caches the State
+// snapshots that make up each tile; it's consulted (and populated) on
+// demand rather than all at once, so only the root tiles are computed
+// eagerly here.
+func makeTraceTree(t *pagetrace.Trace, newSource func(*pagetrace.Trace) (eventSource, error), store TileStore) (*traceTree, error) {
 	maxDur := t.Duration()
 	if maxDur < minTileDuration {
 		maxDur = minTileDuration
@@ -205,41 +245,97 @@ func makeTraceTree(t *pagetrace.Trace) (*traceTree, error) {
 	}
 	maxDur = time.Duration(int64(alignUp(uint64(maxDur), uint64(maxTileDuration))))
 	maxAddr = minAddr + alignUp(maxAddr-minAddr, maxTileMemChunk)
-	trees := make([]*snapNode, maxDur/maxTileDuration)
-	var last *pagetrace.State
-	for i := range trees {
+	tt := &traceTree{
+		t:               t,
+		span:            span{0, maxDur, minAddr, maxAddr},
+		trees:           make([]*snapNode, maxDur/maxTileDuration),
+		height:          height,
+		maxTileDuration: maxTileDuration,
+		maxTileMemChunk: maxTileMemChunk,
+		newSource:       newSource,
+		store:           store,
+	}
+	for i := range tt.trees {
 		start := time.Duration(i) * maxTileDuration
 		end := start + maxTileDuration
+		n := &snapNode{minTime: start, maxTime: end, level: 0, minAddr: minAddr}
+		if i > 0 {
+			n.seedNode, n.seedIdx = tt.trees[i-1], tileSize-1
+		}
+		if _, err := tt.buildNode(n); err != nil {
+			return nil, err
+		}
+		tt.trees[i] = n
+	}
+	return tt, nil
+}
+
+// buildNode (re)computes all of n's snapshots by replaying the
+// relevant slice of the trace, populating tt.store with the result,
+// and returns the populated snapshots.
+func (tt *traceTree) buildNode(n *snapNode) ([]*pagetrace.State, error) {
+	n.buildMu.Lock()
+	defer n.buildMu.Unlock()
+	// Another goroutine may have rebuilt this node while we waited on
+	// the lock; check the cache for the first slot before redoing the
+	// work.
+	tileDuration := (n.maxTime - n.minTime) / tileSize
+	if s, ok := tt.store.Get(n.level, n.minTime, n.minAddr); ok {
+		snaps := make([]*pagetrace.State, tileSize)
+		snaps[0] = s
+		complete := true
+		for i := 1; i < tileSize; i++ {
+			snaps[i], ok = tt.store.Get(n.level, n.minTime+time.Duration(i)*tileDuration, n.minAddr)
+			if !ok {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			return snaps, nil
+		}
+	}
+	var seed *pagetrace.State
+	if n.seedNode != nil {
 		var err error
-		trees[i], last, err = snapNodeRoot(t.Slice(start, end), last)
+		seed, err = tt.nodeSnap(n.seedNode, n.seedIdx)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return &traceTree{
-		t:               t,
-		span:            span{0, maxDur, minAddr, maxAddr},
-		trees:           trees,
-		height:          height,
-		maxTileDuration: maxTileDuration,
-		maxTileMemChunk: maxTileMemChunk,
-	}, nil
+	slice := tt.t.Slice(n.minTime, n.maxTime)
+	log.Print("make node ", slice.TimeStart(), slice.TimeEnd(), tileDuration)
+	src, err := tt.newSource(slice)
+	if err != nil {
+		return nil, err
+	}
+	snaps, _, err := takeSnapshots(src, slice.TimeStart(), slice.TimeEnd(), tileDuration, seed)
+	if err != nil {
+		return nil, err
+	}
+	for i, snap := range snaps {
+		if snap == nil {
+			continue
+		}
+		tt.store.Put(n.level, n.minTime+time.Duration(i)*tileDuration, n.minAddr, snap)
+	}
+	log.Print("finish make node ", slice.TimeStart(), slice.TimeEnd(), tileDuration)
+	return snaps, nil
 }
 
-func snapNodeRoot(t *pagetrace.Trace, s *pagetrace.State) (*snapNode, *pagetrace.State, error) {
-	tileDuration := t.Duration() / tileSize
-	log.Print("make node ", t.TimeStart(), t.TimeEnd(), tileDuration)
-	snaps, last, err := takeSnapshots(t, tileDuration, s)
-	if err != nil {
-		return nil, nil, err
+// nodeSnap returns the i'th of n's tileSize snapshots, rebuilding n
+// from the underlying trace if it (or any of its ancestors) has been
+// evicted from the cache.
+func (tt *traceTree) nodeSnap(n *snapNode, i int) (*pagetrace.State, error) {
+	tileDuration := (n.maxTime - n.minTime) / tileSize
+	if s, ok := tt.store.Get(n.level, n.minTime+time.Duration(i)*tileDuration, n.minAddr); ok {
+		return s, nil
 	}
-	n := &snapNode{
-		minTime: t.TimeStart(),
-		maxTime: t.TimeEnd(),
+	snaps, err := tt.buildNode(n)
+	if err != nil {
+		return nil, err
 	}
-	copy(n.snaps[:], snaps)
-	log.Print("finish make node ", t.TimeStart(), t.TimeEnd(), tileDuration)
-	return n, last, nil
+	return snaps[i], nil
 }
 
 func (tt *traceTree) getTile(tileTime time.Duration, tileAddr uint64, d int) (*image.RGBA, error) {
@@ -262,7 +358,15 @@ func (tt *traceTree) getTile(tileTime time.Duration, tileAddr uint64, d int) (*i
 	depth := 0
 	for depth < tt.height {
 		if d == depth && s.minTime == tileTime && s.minAddr == tileAddr {
-			return makeImage(node.snaps[:], s.minAddr, s.maxAddr, (s.maxAddr-s.minAddr)/tileSize), nil
+			snaps := make([]*pagetrace.State, tileSize)
+			for i := range snaps {
+				var err error
+				snaps[i], err = tt.nodeSnap(node, i)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return makeImage(snaps, s.minAddr, s.maxAddr, (s.maxAddr-s.minAddr)/tileSize), nil
 		}
 		// Go one level deeper.
 
@@ -278,13 +382,15 @@ func (tt *traceTree) getTile(tileTime time.Duration, tileAddr uint64, d int) (*i
 		childSnapIdx := childIdx * tileSize / magFactor
 		node.childLocks[childIdx].Lock()
 		if node.children[childIdx] == nil {
-			// Create the child if it doesn't exist.
-			//
-			// TODO(mknyszek): There's potential to save a lot of memory
-			// since about half of the snapshots we'll generate here are
-			// actually available in the parent.
-			child, _, err := snapNodeRoot(tt.t.Slice(s.minTime, s.maxTime), node.snaps[childSnapIdx])
-			if err != nil {
+			child := &snapNode{
+				minTime:  s.minTime,
+				maxTime:  s.maxTime,
+				level:    node.level + 1,
+				minAddr:  node.minAddr,
+				seedNode: node,
+				seedIdx:  int(childSnapIdx),
+			}
+			if _, err := tt.buildNode(child); err != nil {
 				node.childLocks[childIdx].Unlock()
 				return nil, err
 			}
@@ -0,0 +1,197 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// chromeTraceBuckets is the number of address-range buckets
+// WriteChromeTrace divides the trace's address space into, each
+// becoming its own "process" in the emitted trace. This bounds the
+// number of tracks Perfetto has to render regardless of how much
+// address space the trace covers.
+const chromeTraceBuckets = 16
+
+// noProc is the value Event.P takes when an event happened without a P.
+const noProc = -1
+
+// scavengedTid is the fixed thread ID, within each bucket's process,
+// of the thread tracking that bucket's scavenged byte count.
+const scavengedTid = 0
+
+// tidForProc maps a P to the thread ID of the thread tracking its net
+// allocated bytes within a bucket. Tid 0 is reserved for
+// scavengedTid, so P IDs are offset by 2 instead of 1: P==noProc
+// would otherwise collide with scavengedTid at tid 0.
+func tidForProc(p int32) int {
+	if p == noProc {
+		return 1
+	}
+	return int(p) + 2
+}
+
+// chromeEvent is a single entry in the Trace Event Format consumed by
+// chrome://tracing and the Perfetto UI:
+// https://chromium.googlesource.com/catapult/+/refs/heads/main/tracing/README.md
+type chromeEvent struct {
+	Name string                 `json:"name,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes t to w in the Trace Event Format, for
+// loading into chrome://tracing or https://ui.perfetto.dev. It gives
+// up the PNG dump's fixed time/address resolution for a view that can
+// be panned, zoomed, and searched.
+//
+// The trace's address space is divided into chromeTraceBuckets
+// "processes", each named for the address range it covers. Within a
+// bucket, a "Scavenged" thread tracks the bucket's scavenged byte
+// count, and one thread per P tracks that P's net allocated bytes in
+// the bucket (allocations add, frees subtract, whichever P issued
+// them); a P's track can go negative if it frees more than it
+// allocates in that bucket, which is a real signal that it's mostly
+// freeing memory other Ps allocated. A separate "Totals" process
+// tracks allocated and scavenged bytes across the whole trace.
+func WriteChromeTrace(w io.Writer, t *Trace) error {
+	minAddr, maxAddr := t.MinAddr(), t.MaxAddr()
+	size := maxAddr - minAddr
+	if size == 0 {
+		return fmt.Errorf("trace has no address range to bucket")
+	}
+	bucketSize := alignUp((size+chromeTraceBuckets-1)/chromeTraceBuckets, pageSize)
+	bucketOf := func(addr uint64) int {
+		b := int((addr - minAddr) / bucketSize)
+		if b >= chromeTraceBuckets {
+			b = chromeTraceBuckets - 1
+		}
+		return b
+	}
+
+	enc := json.NewEncoder(w)
+	if _, err := io.WriteString(w, `{"traceEvents":[`); err != nil {
+		return err
+	}
+	first := true
+	emit := func(e chromeEvent) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(e)
+	}
+
+	const totalsPid = 0
+	if err := emit(chromeEvent{Ph: "M", Name: "process_name", Pid: totalsPid, Args: map[string]interface{}{"name": "Totals"}}); err != nil {
+		return err
+	}
+	namedProcess := make([]bool, chromeTraceBuckets)
+	namedThread := make(map[[2]int]bool)
+	ensureNames := func(bucket int, p int32) error {
+		pid := bucket + 1
+		if !namedProcess[bucket] {
+			namedProcess[bucket] = true
+			lo := minAddr + uint64(bucket)*bucketSize
+			hi := lo + bucketSize
+			if hi > maxAddr {
+				hi = maxAddr
+			}
+			name := fmt.Sprintf("[0x%x, 0x%x)", lo, hi)
+			if err := emit(chromeEvent{Ph: "M", Name: "process_name", Pid: pid, Args: map[string]interface{}{"name": name}}); err != nil {
+				return err
+			}
+			if err := emit(chromeEvent{Ph: "M", Name: "thread_name", Pid: pid, Tid: scavengedTid, Args: map[string]interface{}{"name": "Scavenged"}}); err != nil {
+				return err
+			}
+		}
+		tid := tidForProc(p)
+		key := [2]int{bucket, tid}
+		if !namedThread[key] {
+			namedThread[key] = true
+			name := fmt.Sprintf("P %d", p)
+			if p == noProc {
+				name = "no P"
+			}
+			if err := emit(chromeEvent{Ph: "M", Name: "thread_name", Pid: pid, Tid: tid, Args: map[string]interface{}{"name": name}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	netAlloc := make(map[[2]int]int64) // [bucket][tid] -> net allocated bytes
+
+	parser := NewParser(t)
+	var sim Simulator
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sim.Feed(e)
+		ts := float64(e.Time) / 1e3 // nanoseconds to microseconds
+
+		lo, hi := bucketOf(e.Base), bucketOf(e.Base+e.Size-1)
+		for b := lo; b <= hi; b++ {
+			bLo := minAddr + uint64(b)*bucketSize
+			bHi := bLo + bucketSize
+			if bHi > maxAddr {
+				bHi = maxAddr
+			}
+			if err := ensureNames(b, e.P); err != nil {
+				return err
+			}
+			pid := b + 1
+
+			if e.Kind == EventAllocate || e.Kind == EventFree {
+				overlapLo, overlapHi := e.Base, e.Base+e.Size
+				if overlapLo < bLo {
+					overlapLo = bLo
+				}
+				if overlapHi > bHi {
+					overlapHi = bHi
+				}
+				delta := int64(overlapHi - overlapLo)
+				if e.Kind == EventFree {
+					delta = -delta
+				}
+				tid := tidForProc(e.P)
+				key := [2]int{b, tid}
+				netAlloc[key] += delta
+				if err := emit(chromeEvent{Name: "Allocated", Ph: "C", Ts: ts, Pid: pid, Tid: tid, Args: map[string]interface{}{"bytes": netAlloc[key]}}); err != nil {
+					return err
+				}
+			}
+
+			scavenged := sim.Snapshot().Scavenged(bLo, bHi-bLo)
+			if err := emit(chromeEvent{Name: "Scavenged", Ph: "C", Ts: ts, Pid: pid, Tid: scavengedTid, Args: map[string]interface{}{"bytes": int64(scavenged)}}); err != nil {
+				return err
+			}
+		}
+
+		allocated := sim.Snapshot().Allocated(minAddr, size)
+		scavenged := sim.Snapshot().Scavenged(minAddr, size)
+		if err := emit(chromeEvent{Name: "Allocated", Ph: "C", Ts: ts, Pid: totalsPid, Tid: scavengedTid, Args: map[string]interface{}{"bytes": int64(allocated)}}); err != nil {
+			return err
+		}
+		if err := emit(chromeEvent{Name: "Scavenged", Ph: "C", Ts: ts, Pid: totalsPid, Tid: scavengedTid, Args: map[string]interface{}{"bytes": int64(scavenged)}}); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
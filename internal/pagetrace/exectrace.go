@@ -0,0 +1,361 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	exectrace "golang.org/x/exp/trace"
+)
+
+// GCPhase identifies which phase of the garbage collector, if any, was
+// active on a P at a particular point in time.
+type GCPhase uint8
+
+const (
+	// GCOff indicates that the garbage collector was not running.
+	GCOff GCPhase = iota
+	// GCMarkAssist indicates that a goroutine was performing mark
+	// assist on behalf of the garbage collector.
+	GCMarkAssist
+	// GCMark indicates that the concurrent mark phase was active.
+	GCMark
+	// GCMarkTermination indicates that the world was stopped to
+	// terminate the mark phase.
+	GCMarkTermination
+	// GCSweep indicates that the concurrent sweep phase was active.
+	GCSweep
+)
+
+// String returns a human-readable name for the GC phase.
+func (p GCPhase) String() string {
+	switch p {
+	case GCOff:
+		return "off"
+	case GCMarkAssist:
+		return "mark assist"
+	case GCMark:
+		return "mark"
+	case GCMarkTermination:
+		return "mark termination"
+	case GCSweep:
+		return "sweep"
+	}
+	return "unknown"
+}
+
+// Frame is a single frame of a goroutine stack trace, as recorded by
+// an execution trace.
+type Frame struct {
+	Func string
+	File string
+	Line int
+}
+
+// AnnotatedEvent is an Event enriched with the runtime/trace state that
+// was active on its P at the time it occurred.
+type AnnotatedEvent struct {
+	Event
+
+	// GoID is the ID of the goroutine that was running on Event.P at
+	// Event.Time, or 0 if no goroutine could be correlated.
+	GoID uint64
+
+	// Stack is the stack of the goroutine identified by GoID at
+	// Event.Time, innermost frame first.
+	Stack []Frame
+
+	// GCPhase is the phase of the garbage collector that was active
+	// on Event.P at Event.Time.
+	GCPhase GCPhase
+
+	// Regions lists the user regions (see runtime/trace.StartRegion)
+	// active on the goroutine identified by GoID at Event.Time,
+	// outermost first.
+	Regions []string
+}
+
+// procState tracks everything we know about a P's execution trace
+// history so that it can be correlated against page trace events on
+// that P.
+type procState struct {
+	// transitions is a time-ordered history of which goroutine was
+	// running on this P.
+	transitions []goTransition
+
+	// gcPhases is a time-ordered history of the GC phase active on
+	// this P.
+	gcPhases []gcTransition
+}
+
+type goTransition struct {
+	time exectrace.Time
+	goID uint64
+}
+
+type gcTransition struct {
+	time  exectrace.Time
+	phase GCPhase
+}
+
+// goState tracks the stack and active regions of a single goroutine
+// over the lifetime of an execution trace.
+type goState struct {
+	stacks  []stackSnapshot
+	regions []regionSpan
+}
+
+type stackSnapshot struct {
+	time  exectrace.Time
+	stack exectrace.Stack
+}
+
+type regionSpan struct {
+	name       string
+	start, end exectrace.Time // end is exectrace.Time(math.MaxInt64) if still open
+}
+
+// ExecTraceIndex is the per-P and per-goroutine execution trace
+// history built by IndexExecTrace. It's kept separate from
+// AnnotatedParser so that indexing an execution trace — a full
+// forward pass over it — only has to happen once no matter how many
+// AnnotatedParsers end up correlating page trace slices against it;
+// see NewAnnotatedParserFromIndex.
+type ExecTraceIndex struct {
+	procs map[int32]*procState
+	goros map[uint64]*goState
+}
+
+// IndexExecTrace performs a single forward pass over an execution
+// trace, building the per-P and per-goroutine histories an
+// AnnotatedParser uses to answer its queries.
+func IndexExecTrace(r io.Reader) (*ExecTraceIndex, error) {
+	idx := &ExecTraceIndex{
+		procs: make(map[int32]*procState),
+		goros: make(map[uint64]*goState),
+	}
+	if err := idx.build(r); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *ExecTraceIndex) build(r io.Reader) error {
+	tr, err := exectrace.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("reading execution trace: %v", err)
+	}
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading execution trace: %v", err)
+		}
+		switch ev.Kind() {
+		case exectrace.EventStateTransition:
+			st := ev.StateTransition()
+			if res := st.Resource; res.Kind == exectrace.ResourceGoroutine {
+				// If the goroutine just started or stopped running on
+				// a P, record which goroutine (0 for none) that P is
+				// now running. Every transition is also recorded in
+				// the goroutine's own stack history.
+				from, to := st.Goroutine()
+				if proc := ev.Proc(); proc != exectrace.NoProc && (from == exectrace.GoRunning || to == exectrace.GoRunning) {
+					goID := uint64(res.Goroutine())
+					if to != exectrace.GoRunning {
+						goID = 0
+					}
+					ps := idx.proc(int32(proc))
+					ps.transitions = append(ps.transitions, goTransition{
+						time: ev.Time(),
+						goID: goID,
+					})
+				}
+				gs := idx.goro(uint64(res.Goroutine()))
+				gs.stacks = append(gs.stacks, stackSnapshot{time: ev.Time(), stack: ev.Stack()})
+			}
+		case exectrace.EventRangeBegin, exectrace.EventRangeActive:
+			rg := ev.Range()
+			if phase, ok := gcPhaseForRange(rg.Name); ok {
+				if proc := ev.Proc(); proc != exectrace.NoProc {
+					ps := idx.proc(int32(proc))
+					ps.gcPhases = append(ps.gcPhases, gcTransition{time: ev.Time(), phase: phase})
+				}
+				continue
+			}
+			if goid := ev.Goroutine(); goid != exectrace.NoGoroutine {
+				gs := idx.goro(uint64(goid))
+				gs.regions = append(gs.regions, regionSpan{name: rg.Name, start: ev.Time(), end: exectrace.Time(maxTime)})
+			}
+		case exectrace.EventRangeEnd:
+			rg := ev.Range()
+			if _, ok := gcPhaseForRange(rg.Name); ok {
+				if proc := ev.Proc(); proc != exectrace.NoProc {
+					ps := idx.proc(int32(proc))
+					ps.gcPhases = append(ps.gcPhases, gcTransition{time: ev.Time(), phase: GCOff})
+				}
+				continue
+			}
+			if goid := ev.Goroutine(); goid != exectrace.NoGoroutine {
+				gs := idx.goro(uint64(goid))
+				for i := len(gs.regions) - 1; i >= 0; i-- {
+					if gs.regions[i].name == rg.Name && gs.regions[i].end == exectrace.Time(maxTime) {
+						gs.regions[i].end = ev.Time()
+						break
+					}
+				}
+			}
+		case exectrace.EventRegionBegin, exectrace.EventRegionEnd:
+			goid := ev.Goroutine()
+			if goid == exectrace.NoGoroutine {
+				continue
+			}
+			gs, name := idx.goro(uint64(goid)), ev.Region().Type
+			if ev.Kind() == exectrace.EventRegionBegin {
+				gs.regions = append(gs.regions, regionSpan{name: name, start: ev.Time(), end: exectrace.Time(maxTime)})
+				continue
+			}
+			for i := len(gs.regions) - 1; i >= 0; i-- {
+				if gs.regions[i].name == name && gs.regions[i].end == exectrace.Time(maxTime) {
+					gs.regions[i].end = ev.Time()
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+const maxTime = int64(^uint64(0) >> 1)
+
+func gcPhaseForRange(name string) (GCPhase, bool) {
+	switch name {
+	case "GC concurrent mark":
+		return GCMark, true
+	case "GC mark termination":
+		return GCMarkTermination, true
+	case "GC concurrent sweep":
+		return GCSweep, true
+	case "GC mark assist":
+		return GCMarkAssist, true
+	}
+	return GCOff, false
+}
+
+func (idx *ExecTraceIndex) proc(pid int32) *procState {
+	ps, ok := idx.procs[pid]
+	if !ok {
+		ps = &procState{}
+		idx.procs[pid] = ps
+	}
+	return ps
+}
+
+func (idx *ExecTraceIndex) goro(goid uint64) *goState {
+	gs, ok := idx.goros[goid]
+	if !ok {
+		gs = &goState{}
+		idx.goros[goid] = gs
+	}
+	return gs
+}
+
+// AnnotatedParser joins a page trace Parser with an ExecTraceIndex so
+// that each Event can be annotated with the goroutine, stack, GC
+// phase, and user regions active on its P at the time it occurred.
+type AnnotatedParser struct {
+	p   *Parser
+	idx *ExecTraceIndex
+
+	buildErr error
+}
+
+// NewAnnotatedParser creates an AnnotatedParser for pt that annotates
+// events using the execution trace read from execTrace.
+//
+// The execution trace is expected to have been collected concurrently
+// with the page trace, covering at least the same time range.
+//
+// Any error encountered while indexing execTrace is deferred to the
+// first call to Next. To correlate several page trace slices against
+// the same execution trace, build an ExecTraceIndex once with
+// IndexExecTrace and share it across AnnotatedParsers with
+// NewAnnotatedParserFromIndex instead of calling NewAnnotatedParser
+// repeatedly, which re-indexes the execution trace every time.
+func NewAnnotatedParser(pt *Trace, execTrace io.Reader) *AnnotatedParser {
+	idx, err := IndexExecTrace(execTrace)
+	if err != nil {
+		return &AnnotatedParser{p: NewParser(pt), buildErr: err}
+	}
+	return NewAnnotatedParserFromIndex(pt, idx)
+}
+
+// NewAnnotatedParserFromIndex creates an AnnotatedParser for pt that
+// annotates events using idx, an ExecTraceIndex built ahead of time by
+// IndexExecTrace. Unlike NewAnnotatedParser, this never re-scans the
+// execution trace, so it's cheap to call once per page trace slice
+// against a single shared idx.
+func NewAnnotatedParserFromIndex(pt *Trace, idx *ExecTraceIndex) *AnnotatedParser {
+	return &AnnotatedParser{p: NewParser(pt), idx: idx}
+}
+
+// Next returns the next event in the parse stream, annotated with the
+// execution trace state that was active on its P at the time it
+// occurred.
+//
+// Returns io.EOF at the end of the stream.
+func (ap *AnnotatedParser) Next() (AnnotatedEvent, error) {
+	if ap.buildErr != nil {
+		return AnnotatedEvent{}, ap.buildErr
+	}
+	e, err := ap.p.Next()
+	if err != nil {
+		return AnnotatedEvent{}, err
+	}
+	ae := AnnotatedEvent{Event: e}
+	ps, ok := ap.idx.procs[e.P]
+	if !ok {
+		return ae, nil
+	}
+	t := exectrace.Time(e.Time)
+	if i := lastAtOrBefore(len(ps.transitions), func(i int) bool { return ps.transitions[i].time > t }); i >= 0 {
+		ae.GoID = ps.transitions[i].goID
+	}
+	if i := lastAtOrBefore(len(ps.gcPhases), func(i int) bool { return ps.gcPhases[i].time > t }); i >= 0 {
+		ae.GCPhase = ps.gcPhases[i].phase
+	}
+	if gs, ok := ap.idx.goros[ae.GoID]; ok {
+		if i := lastAtOrBefore(len(gs.stacks), func(i int) bool { return gs.stacks[i].time > t }); i >= 0 {
+			ae.Stack = frames(gs.stacks[i].stack)
+		}
+		for _, r := range gs.regions {
+			if r.start <= t && t < r.end {
+				ae.Regions = append(ae.Regions, r.name)
+			}
+		}
+	}
+	return ae, nil
+}
+
+// lastAtOrBefore returns the index of the last element for which
+// after (testing "time > t" on a slice sorted ascending by time)
+// returns false, i.e. the last element at or before the queried time.
+// Returns -1 if every element is after the queried time.
+func lastAtOrBefore(n int, after func(int) bool) int {
+	i := sort.Search(n, after)
+	return i - 1
+}
+
+func frames(s exectrace.Stack) []Frame {
+	var fs []Frame
+	for f := range s.Frames() {
+		fs = append(fs, Frame{Func: f.Func, File: f.File, Line: int(f.Line)})
+	}
+	return fs
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import "math/bits"
+
+// freeRun is a maximal contiguous run of free (unallocated) pages.
+type freeRun struct {
+	base, size uint64
+}
+
+// freeRuns returns the maximal contiguous runs of free pages in s, in
+// address order. It's the free-page analog of allocatedRuns.
+func (s *State) freeRuns() []freeRun {
+	var runs []freeRun
+	var start uint64
+	inRun := false
+	n := uint64(len(s.allocBits)) * 8
+	for i := uint64(0); i < n; i++ {
+		addr := s.minAddr + i*pageSize
+		free := s.allocBits[i/8]&(1<<(i%8)) == 0
+		switch {
+		case free && !inRun:
+			start, inRun = addr, true
+		case !free && inRun:
+			runs = append(runs, freeRun{base: start, size: addr - start})
+			inRun = false
+		}
+	}
+	if inRun {
+		runs = append(runs, freeRun{base: start, size: s.minAddr + n*pageSize - start})
+	}
+	return runs
+}
+
+// Fragmentation returns 1 minus the fraction of free memory contained
+// in the single largest contiguous free run. 0 means every free page
+// is part of one contiguous run (no fragmentation); values approaching
+// 1 mean free memory is scattered across many runs much smaller than
+// the largest. Returns 0 if there's no free memory at all.
+func (s *Simulator) Fragmentation() float64 {
+	runs := s.state.freeRuns()
+	var total, largest uint64
+	for _, r := range runs {
+		total += r.size
+		if r.size > largest {
+			largest = r.size
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 1 - float64(largest)/float64(total)
+}
+
+// ScavengedRatio returns the fraction of currently-free memory that's
+// been scavenged back to the OS, a measure of how well the scavenger
+// is keeping up with what's free. Returns 1 if there's no free memory,
+// since there's nothing left for the scavenger to do.
+func (s *Simulator) ScavengedRatio() float64 {
+	state := &s.state
+	free := state.Size() - state.Allocated(state.MinAddr(), state.Size())
+	if free == 0 {
+		return 1
+	}
+	scavenged := state.Scavenged(state.MinAddr(), state.Size())
+	return float64(scavenged) / float64(free)
+}
+
+// FreeRunHistogram buckets the current free runs by power-of-two page
+// count: bucket i counts runs whose length in pages falls in
+// [2**i, 2**(i+1)). The returned slice is only as long as needed to
+// hold the largest free run currently observed.
+func (s *Simulator) FreeRunHistogram() []int {
+	var hist []int
+	for _, r := range s.state.freeRuns() {
+		pages := r.size / pageSize
+		if pages == 0 {
+			continue
+		}
+		b := bits.Len64(pages) - 1
+		for len(hist) <= b {
+			hist = append(hist, 0)
+		}
+		hist[b]++
+	}
+	return hist
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	exectrace "golang.org/x/exp/trace"
+)
+
+// rawEvent is a single page-granularity allocate/free/scavenge event
+// as reported by the execution tracer's experimental "traceallocfree"
+// GODEBUG, before being re-encoded into the page trace's own wire
+// format.
+type rawEvent struct {
+	time   int64
+	proc   int32
+	kind   eventKind // alloc, free, or scav; never the Large variants
+	base   uint64
+	npages uint64
+}
+
+// experimentalPageEventKind maps the name of an experimental trace
+// event emitted under GODEBUG=traceallocfree to the page trace event
+// kind it corresponds to. Names are as defined by the runtime's
+// tracer; see the "traceallocfree" experiment in
+// src/runtime/trace2runtime.go upstream.
+func experimentalPageEventKind(name string) (eventKind, bool) {
+	switch name {
+	case "PageAlloc":
+		return alloc, true
+	case "PageFree":
+		return free, true
+	case "PageScavenge":
+		return scav, true
+	}
+	return 0, false
+}
+
+// NewTraceFromExecTrace builds a Trace from the page-level
+// allocate/free/scavenge events recorded in an execution trace
+// collected with GODEBUG=traceallocfree=1. This lets the pagetrace
+// tooling keep working against Go 1.23+ traces, which no longer carry
+// a standalone page trace: the runtime folds page events into the
+// regular execution trace as an experimental batch instead of writing
+// them to a side channel.
+//
+// The events are re-encoded into the same wire format NewTrace
+// expects, so the Trace it returns is fully interchangeable with one
+// built from a standalone page trace file, and works unmodified with
+// Parser, Simulator, and the rest of this package.
+func NewTraceFromExecTrace(r io.Reader) (*Trace, error) {
+	events, err := readRawEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewTrace(bytes.NewReader(encodeRawEvents(events)))
+}
+
+// readRawEvents extracts every page-granularity allocate/free/scavenge
+// event from an execution trace, in the order the tracer recorded
+// them.
+func readRawEvents(r io.Reader) ([]rawEvent, error) {
+	tr, err := exectrace.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading execution trace: %v", err)
+	}
+	var events []rawEvent
+	for {
+		ev, err := tr.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading execution trace: %v", err)
+		}
+		if ev.Kind() != exectrace.EventExperimental {
+			continue
+		}
+		exp := ev.Experimental()
+		k, ok := experimentalPageEventKind(exp.Name)
+		if !ok {
+			continue
+		}
+		if len(exp.Args) < 2 {
+			return nil, fmt.Errorf("malformed %s event: want 2 args, got %d", exp.Name, len(exp.Args))
+		}
+		proc := ev.Proc()
+		base, size := exp.ArgValue(0).Uint64(), exp.ArgValue(1).Uint64()
+		if base%pageSize != 0 || size%pageSize != 0 {
+			return nil, fmt.Errorf("%s event region [0x%x, 0x%x) isn't page-aligned", exp.Name, base, base+size)
+		}
+		events = append(events, rawEvent{
+			time:   int64(ev.Time()),
+			proc:   int32(proc),
+			kind:   k,
+			base:   base,
+			npages: size / pageSize,
+		})
+	}
+	return events, nil
+}
+
+// maxDelta is the largest delta, in nanoseconds, that can be encoded
+// relative to a block's sync event. Once a P's events would exceed it,
+// encodeRawEvents starts a fresh block for that P with a new sync
+// event.
+const maxDelta = int64(((1 << timeDeltaBits) - 1)) << timeLostBits
+
+// encodeRawEvents re-encodes events, which need not be sorted, into
+// the page trace wire format NewTrace parses: one block per P
+// containing a pid event, a sync event, and then each of that P's
+// events in increasing time order as a delta off the sync event,
+// starting a new block whenever the delta would overflow.
+func encodeRawEvents(events []rawEvent) []byte {
+	byProc := make(map[int32][]rawEvent)
+	for _, e := range events {
+		byProc[e.proc] = append(byProc[e.proc], e)
+	}
+	var procs []int32
+	for p := range byProc {
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i] < procs[j] })
+
+	var buf bytes.Buffer
+	var hdr [8]byte
+	writeHeader := func(h eventHeader) {
+		binary.LittleEndian.PutUint64(hdr[:], uint64(h))
+		buf.Write(hdr[:])
+	}
+	for _, p := range procs {
+		es := byProc[p]
+		sort.Slice(es, func(i, j int) bool { return es[i].time < es[j].time })
+
+		syncTime := es[0].time
+		writeHeader(makePidHeader(p))
+		writeHeader(makeSyncHeader(syncTime))
+		for _, e := range es {
+			if delta := e.time - syncTime; delta > maxDelta {
+				// Start a fresh block so the delta fits.
+				syncTime = e.time
+				writeHeader(makePidHeader(p))
+				writeHeader(makeSyncHeader(syncTime))
+			}
+			k := e.kind
+			large := e.npages > (1<<10)-1
+			if large {
+				k |= eventKind(1 << 2)
+			}
+			writeHeader(makeEventHeader(k, e.base, e.npages, e.time-syncTime))
+			if large {
+				binary.LittleEndian.PutUint64(hdr[:], e.npages)
+				buf.Write(hdr[:])
+			}
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestEncodeRawEventsRoundTrip checks that events encoded by
+// encodeRawEvents into the page trace wire format come back out of
+// NewTrace/Parser unchanged and in time order, across several Ps.
+func TestEncodeRawEventsRoundTrip(t *testing.T) {
+	// Times are chosen as multiples of the wire format's clock
+	// granularity (1<<timeLostBits ns) and well away from 0, since a
+	// sync or delta that quantizes down to the all-zero header would
+	// otherwise be indistinguishable from this package's "no more
+	// events" sentinel. Event.Time is reported relative to the
+	// earliest sync time across all Ps (here, proc 0's), not the raw
+	// input times.
+	in := []rawEvent{
+		{time: 38400, proc: 0, kind: free, base: 2 * pageSize, npages: 1},
+		{time: 12800, proc: 0, kind: alloc, base: 0, npages: 2},
+		{time: 25600, proc: 1, kind: alloc, base: 4 * pageSize, npages: 1},
+		{time: 51200, proc: 1, kind: scav, base: 4 * pageSize, npages: 1},
+	}
+	want := []Event{
+		{Kind: EventAllocate, P: 0, Time: 0, Base: 0, Size: 2 * pageSize},
+		{Kind: EventAllocate, P: 1, Time: 12800, Base: 4 * pageSize, Size: pageSize},
+		{Kind: EventFree, P: 0, Time: 25600, Base: 2 * pageSize, Size: pageSize},
+		{Kind: EventScavenge, P: 1, Time: 38400, Base: 4 * pageSize, Size: pageSize},
+	}
+
+	tr, err := NewTrace(bytes.NewReader(encodeRawEvents(in)))
+	if err != nil {
+		t.Fatalf("NewTrace: %v", err)
+	}
+	p := NewParser(tr)
+
+	var got []Event
+	for {
+		e, err := p.Next()
+		if err != nil {
+			break
+		}
+		got = append(got, e)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+// TestEncodeRawEventsLargeNPages checks that a page run too big to fit
+// in the inline npages field round-trips through its 8-byte trailer.
+func TestEncodeRawEventsLargeNPages(t *testing.T) {
+	const npages = (1 << 10) + 5 // exceeds the inline npages field
+	in := []rawEvent{
+		{time: 12800, proc: 0, kind: alloc, base: 0, npages: npages},
+	}
+	tr, err := NewTrace(bytes.NewReader(encodeRawEvents(in)))
+	if err != nil {
+		t.Fatalf("NewTrace: %v", err)
+	}
+	e, err := NewParser(tr).Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if want := uint64(npages) * pageSize; e.Size != want {
+		t.Errorf("Size = %d, want %d", e.Size, want)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"golang.org/x/exp/mmap"
+)
+
+// OpenMmap opens filename and memory-maps its contents, returning an
+// io.ReaderAt suitable for NewTrace or NewTraceOptions. It avoids
+// reading the whole trace into memory up front, which matters for
+// traces too large to comfortably fit in RAM.
+//
+// The caller is responsible for closing the returned ReaderAt once
+// done with the Trace built from it.
+func OpenMmap(filename string) (*mmap.ReaderAt, error) {
+	return mmap.Open(filename)
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"io"
+	"time"
+)
+
+// mudBuckets is the number of fixed-width buckets MUD uses to
+// histogram utilization values in [0, 1]. A fixed bucket count bounds
+// the histogram's memory use independent of trace length, at the cost
+// of some resolution at the tails relative to a fully adaptive
+// histogram.
+const mudBuckets = 4096
+
+// MUD is a "mutator utilization distribution": a histogram of how
+// much wall-clock time a trace spent at each level of some quantity
+// in [0, 1], in the style of go tool trace's MUD/MMU analysis of GC
+// pause impact on the mutator. MemoryUtilization builds one for page
+// occupancy instead of mutator time, so that memory pressure can be
+// queried the same way GC pause impact can: by quantile, or by
+// locating the single worst window.
+type MUD struct {
+	weight [mudBuckets]time.Duration
+	total  time.Duration
+
+	haveWorst bool
+	worstTime time.Duration
+	worstUtil float64
+}
+
+func newMUD() *MUD {
+	return &MUD{}
+}
+
+func (m *MUD) bucket(util float64) int {
+	i := int(util * mudBuckets)
+	if i < 0 {
+		i = 0
+	}
+	if i >= mudBuckets {
+		i = mudBuckets - 1
+	}
+	return i
+}
+
+// add records a window ending at t with the given utilization
+// persisting for dur.
+func (m *MUD) add(t time.Duration, util float64, dur time.Duration) {
+	if dur <= 0 {
+		return
+	}
+	m.weight[m.bucket(util)] += dur
+	m.total += dur
+	if !m.haveWorst || util > m.worstUtil {
+		m.haveWorst = true
+		m.worstUtil = util
+		m.worstTime = t
+	}
+}
+
+// Quantile returns the utilization u such that a fraction q of the
+// distribution's total weight came from windows with utilization at
+// most u. q must be in [0, 1].
+func (m *MUD) Quantile(q float64) float64 {
+	if m.total == 0 {
+		return 0
+	}
+	target := time.Duration(q * float64(m.total))
+	var sum time.Duration
+	for i, w := range m.weight {
+		sum += w
+		if sum >= target {
+			return float64(i+1) / mudBuckets
+		}
+	}
+	return 1
+}
+
+// WorstWindow returns the end time and utilization of the
+// highest-utilization (most memory-pressured) window observed.
+func (m *MUD) WorstWindow() (time.Duration, float64) {
+	return m.worstTime, m.worstUtil
+}
+
+// muSegment records memory utilization immediately after one event,
+// held constant until the next one. MemoryUtilizationWindows builds a
+// single []muSegment per trace and reuses it to compute the MUD for
+// every window size it's asked for.
+type muSegment struct {
+	start time.Duration
+	util  float64
+}
+
+// MemoryUtilization computes the distribution of memory utilization
+// -- the fraction of the trace's observed address range that's
+// allocated -- over every sliding window of the given size, by
+// streaming through t's events once.
+//
+// Utilization is sampled once per event, using the state immediately
+// after the event is applied and held constant until the next event.
+// A window is only added to the distribution once it's fully
+// elapsed, so MemoryUtilization returns an empty MUD for traces
+// shorter than window.
+//
+// To sample several window sizes against the same trace, call
+// MemoryUtilizationWindows instead: it amortizes the cost of
+// streaming through t's events across every window size, rather than
+// replaying the whole trace once per call as repeated calls to
+// MemoryUtilization would.
+func (t *Trace) MemoryUtilization(window time.Duration) (*MUD, error) {
+	muds, err := t.MemoryUtilizationWindows([]time.Duration{window})
+	if err != nil {
+		return nil, err
+	}
+	return muds[0], nil
+}
+
+// MemoryUtilizationWindows is like MemoryUtilization, but computes the
+// distribution for every window size in windows from a single forward
+// pass over t's events, rather than one pass per window size.
+func (t *Trace) MemoryUtilizationWindows(windows []time.Duration) ([]*MUD, error) {
+	muds := make([]*MUD, len(windows))
+	for i := range muds {
+		muds[i] = newMUD()
+	}
+	size := t.maxAddr - t.minAddr
+	if size == 0 {
+		return muds, nil
+	}
+	parser := NewParser(t)
+	var sim Simulator
+
+	var segs []muSegment
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sim.Feed(e)
+		util := float64(sim.Snapshot().Allocated(t.minAddr, size)) / float64(size)
+		segs = append(segs, muSegment{start: e.Time, util: util})
+	}
+
+	traceStart, traceEnd := t.TimeStart(), t.TimeEnd()
+	for i, window := range windows {
+		if window > 0 {
+			memoryUtilizationWindow(segs, window, traceStart, traceEnd, muds[i])
+		}
+	}
+	return muds, nil
+}
+
+// memoryUtilizationWindow fills m with the distribution of utilization
+// over every sliding window of the given size, computed from segs, the
+// precomputed per-event utilization samples built by
+// MemoryUtilizationWindows.
+func memoryUtilizationWindow(segs []muSegment, window, traceStart, traceEnd time.Duration, m *MUD) {
+	var windowIntegral float64 // sum of util*dt over segs[lo:]
+	lo := 0
+
+	var havePending bool
+	var pendingTime time.Duration
+	var pendingUtil float64
+	flush := func(upto time.Duration) {
+		if havePending && upto > pendingTime {
+			m.add(upto, pendingUtil, upto-pendingTime)
+		}
+	}
+
+	for i, cur := range segs {
+		if i > 0 {
+			last := segs[i-1]
+			windowIntegral += last.util * float64(cur.start-last.start)
+		}
+
+		// Drop segments that have fully fallen out of the trailing
+		// window. This only advances lo at segment boundaries rather
+		// than trimming a segment's partial contribution, so the span
+		// actually covered by segs[lo:] can be slightly wider than
+		// window; the utilization recorded below is normalized against
+		// that actual span rather than the nominal window size.
+		cutoff := cur.start - window
+		for lo < i && segs[lo+1].start <= cutoff {
+			windowIntegral -= segs[lo].util * float64(segs[lo+1].start-segs[lo].start)
+			lo++
+		}
+		if cur.start-traceStart < window {
+			continue
+		}
+		span := cur.start - segs[lo].start
+		if span <= 0 {
+			continue
+		}
+		flush(cur.start)
+		pendingTime, pendingUtil, havePending = cur.start, windowIntegral/float64(span), true
+	}
+	flush(traceEnd)
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMUDQuantileEmpty(t *testing.T) {
+	m := newMUD()
+	if got := m.Quantile(0.5); got != 0 {
+		t.Errorf("Quantile(0.5) on empty MUD = %v, want 0", got)
+	}
+}
+
+func TestMUDQuantile(t *testing.T) {
+	m := newMUD()
+	m.add(1*time.Second, 0.0, 1*time.Second)
+	m.add(2*time.Second, 0.5, 1*time.Second)
+	m.add(4*time.Second, 1.0, 2*time.Second)
+
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0, 1.0 / mudBuckets},
+		{0.5, 2049.0 / mudBuckets},
+		{1, 1.0},
+	}
+	for _, test := range tests {
+		got := m.Quantile(test.q)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Quantile(%v) = %v, want %v", test.q, got, test.want)
+		}
+	}
+}
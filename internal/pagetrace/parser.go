@@ -148,6 +148,10 @@ const (
 	heapAddrBits  = 48
 )
 
+// PageSize is the granularity, in bytes, at which the page trace
+// tracks memory.
+const PageSize = pageSize
+
 // eventHeader represents an encoded 8-byte event header. For most events,
 // this is the entire event.
 type eventHeader uint64
@@ -216,6 +220,38 @@ func (e eventHeader) npagesSmall() uint64 {
 	return (uint64(e) >> kindBits) & ((1 << 10) - 1)
 }
 
+// makeSyncHeader encodes a sync event carrying the absolute timestamp
+// ts, rounded down to the granularity the format supports. It's the
+// inverse of eventHeader.timestamp.
+func makeSyncHeader(ts int64) eventHeader {
+	return eventHeader(uint64(ts>>timeLostBits) << kindBits)
+}
+
+// makePidHeader encodes a pid event for p. It's the inverse of
+// eventHeader.pid.
+func makePidHeader(p int32) eventHeader {
+	return eventHeader(uint64(int64(p)<<kindBits) | uint64(pid))
+}
+
+// makeEventHeader encodes a non-sync, non-pid event of the given kind
+// for the page-aligned region [base, base+npages*pageSize), occurring
+// delta nanoseconds after the block's last sync event. delta is
+// rounded down to the granularity the format supports. npages is
+// encoded inline, truncated to its low 10 bits, unless k has the
+// large bit set (see large()); in that case it's left out of the
+// header and the caller must follow up with an 8-byte little-endian
+// npages trailer. It's the inverse of kind, large, base, npagesSmall,
+// and timestampDelta.
+func makeEventHeader(k eventKind, base uint64, npages uint64, delta int64) eventHeader {
+	h := uint64(k)
+	if !eventHeader(k).large() {
+		h |= (npages & ((1 << 10) - 1)) << kindBits
+	}
+	h |= base &^ (pageSize - 1)
+	h |= (uint64(delta>>timeLostBits) & ((1 << timeDeltaBits) - 1)) << (64 - timeDeltaBits)
+	return eventHeader(h)
+}
+
 // Kind is the event type.
 type Kind uint8
 
@@ -0,0 +1,140 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/pprof/profile"
+)
+
+// pageRun is a maximal contiguous run of allocated pages.
+type pageRun struct {
+	base, size uint64
+}
+
+// allocatedRuns returns the maximal contiguous runs of allocated pages
+// in s, in address order.
+func (s *State) allocatedRuns() []pageRun {
+	var runs []pageRun
+	var start uint64
+	inRun := false
+	n := uint64(len(s.allocBits)) * 8
+	for i := uint64(0); i < n; i++ {
+		addr := s.minAddr + i*pageSize
+		alloc := s.allocBits[i/8]&(1<<(i%8)) != 0
+		switch {
+		case alloc && !inRun:
+			start, inRun = addr, true
+		case !alloc && inRun:
+			runs = append(runs, pageRun{base: start, size: addr - start})
+			inRun = false
+		}
+	}
+	if inRun {
+		runs = append(runs, pageRun{base: start, size: s.minAddr + n*pageSize - start})
+	}
+	return runs
+}
+
+// WriteProfile writes s as a pprof profile.proto to w. Each sample
+// corresponds to one maximal contiguous run of allocated pages; its
+// value is the size of the run in bytes, under a sample type named
+// sampleType, and it carries a {scavenged, base, size} label set so the original
+// page-trace addresses can be recovered from `go tool pprof`.
+func (s *State) WriteProfile(w io.Writer, sampleType string) error {
+	return writeProfile(w, s, sampleType, nil)
+}
+
+// AllocationStacks runs ap to completion, returning the final State
+// together with the allocating stack recorded for every page that's
+// allocated in that State, keyed by page-aligned address.
+func (ap *AnnotatedParser) AllocationStacks() (*State, map[uint64][]Frame, error) {
+	var sim Simulator
+	stacks := make(map[uint64][]Frame)
+	for {
+		ae, err := ap.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		sim.Feed(ae.Event)
+		switch ae.Kind {
+		case EventAllocate:
+			for a := ae.Base; a < ae.Base+ae.Size; a += pageSize {
+				stacks[a] = ae.Stack
+			}
+		case EventFree:
+			for a := ae.Base; a < ae.Base+ae.Size; a += pageSize {
+				delete(stacks, a)
+			}
+		}
+	}
+	return sim.Snapshot().Clone(), stacks, nil
+}
+
+// WriteAnnotatedProfile is like (*State).WriteProfile, but additionally
+// attaches the allocating stack recorded in stacks (as produced by
+// AnnotatedParser.AllocationStacks) to each sample's Location list, so
+// that `go tool pprof -list`, `-web`, and flame graphs can be produced
+// directly against page-trace data.
+func WriteAnnotatedProfile(w io.Writer, s *State, sampleType string, stacks map[uint64][]Frame) error {
+	return writeProfile(w, s, sampleType, stacks)
+}
+
+func writeProfile(w io.Writer, s *State, sampleType string, stacks map[uint64][]Frame) error {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: sampleType, Unit: "bytes"}},
+		PeriodType: &profile.ValueType{Type: sampleType, Unit: "bytes"},
+		Period:     1,
+	}
+	funcs := make(map[string]*profile.Function)
+	locs := make(map[string]*profile.Location)
+	for _, run := range s.allocatedRuns() {
+		sample := &profile.Sample{
+			Value: []int64{int64(run.size)},
+			Label: map[string][]string{
+				"scavenged": {fmt.Sprint(s.Scavenged(run.base, run.size) == run.size)},
+			},
+			NumLabel: map[string][]int64{
+				"base": {int64(run.base)},
+				"size": {int64(run.size)},
+			},
+			NumUnit: map[string][]string{
+				"base": {"bytes"},
+				"size": {"bytes"},
+			},
+		}
+		for _, frame := range stacks[run.base] {
+			key := fmt.Sprintf("%s:%s:%d", frame.Func, frame.File, frame.Line)
+			loc, ok := locs[key]
+			if !ok {
+				fn, ok := funcs[frame.Func]
+				if !ok {
+					fn = &profile.Function{
+						ID:         uint64(len(p.Function) + 1),
+						Name:       frame.Func,
+						SystemName: frame.Func,
+						Filename:   frame.File,
+					}
+					funcs[frame.Func] = fn
+					p.Function = append(p.Function, fn)
+				}
+				loc = &profile.Location{
+					ID:   uint64(len(p.Location) + 1),
+					Line: []profile.Line{{Function: fn, Line: int64(frame.Line)}},
+				}
+				locs[key] = loc
+				p.Location = append(p.Location, loc)
+			}
+			sample.Location = append(sample.Location, loc)
+		}
+		p.Sample = append(p.Sample, sample)
+	}
+	return p.Write(w)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// TestWriteProfile checks that WriteProfile emits one sample per
+// contiguous allocated run, with the run's size as its value and its
+// scavenged state as a label, and that the result parses back as a
+// valid pprof profile.
+func TestWriteProfile(t *testing.T) {
+	var sim Simulator
+	feedAll(&sim,
+		Event{Kind: EventAllocate, Base: 0, Size: 3 * pageSize},
+		Event{Kind: EventFree, Base: 1 * pageSize, Size: pageSize},
+		Event{Kind: EventAllocate, Base: 4 * pageSize, Size: pageSize},
+		Event{Kind: EventScavenge, Base: 4 * pageSize, Size: pageSize},
+	)
+	state := sim.Snapshot()
+
+	var buf bytes.Buffer
+	if err := state.WriteProfile(&buf, "bytes"); err != nil {
+		t.Fatalf("WriteProfile: %v", err)
+	}
+	p, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatalf("profile.Parse: %v", err)
+	}
+
+	wantRuns := []struct {
+		base, size uint64
+		scavenged  bool
+	}{
+		{0, pageSize, false},
+		{2 * pageSize, pageSize, false},
+		{4 * pageSize, pageSize, true},
+	}
+	if len(p.Sample) != len(wantRuns) {
+		t.Fatalf("got %d samples, want %d", len(p.Sample), len(wantRuns))
+	}
+	for i, want := range wantRuns {
+		s := p.Sample[i]
+		if got := uint64(s.Value[0]); got != want.size {
+			t.Errorf("sample %d value = %d, want %d", i, got, want.size)
+		}
+		if got := uint64(s.NumLabel["base"][0]); got != want.base {
+			t.Errorf("sample %d base = %d, want %d", i, got, want.base)
+		}
+		if got := s.Label["scavenged"][0]; got != fmt.Sprint(want.scavenged) {
+			t.Errorf("sample %d scavenged = %s, want %v", i, got, want.scavenged)
+		}
+	}
+}
@@ -144,6 +144,136 @@ func (s *State) Scavenged(addr, size uint64) uint64 {
 	return sum
 }
 
+// TransitionClass categorizes how a single page changed between two
+// State snapshots.
+type TransitionClass uint8
+
+const (
+	// Unchanged indicates the page's allocated/scavenged status is the
+	// same in both states.
+	Unchanged TransitionClass = iota
+	// NewlyAllocated indicates the page became allocated.
+	NewlyAllocated
+	// NewlyFreed indicates the page became free.
+	NewlyFreed
+	// NewlyScavenged indicates the page became scavenged. A page is
+	// only classified this way if it didn't also change allocated
+	// status.
+	NewlyScavenged
+)
+
+// String returns a human-readable name for the transition class.
+func (c TransitionClass) String() string {
+	switch c {
+	case Unchanged:
+		return "unchanged"
+	case NewlyAllocated:
+		return "newly allocated"
+	case NewlyFreed:
+		return "newly freed"
+	case NewlyScavenged:
+		return "newly scavenged"
+	}
+	return "unknown"
+}
+
+// StateDiff is the per-page difference between two State snapshots, as
+// produced by State.Diff.
+type StateDiff struct {
+	minAddr uint64
+	classes []TransitionClass
+
+	// NewlyAllocatedBytes is the total number of bytes that became
+	// allocated.
+	NewlyAllocatedBytes uint64
+	// NewlyFreedBytes is the total number of bytes that became free.
+	NewlyFreedBytes uint64
+	// NewlyScavengedBytes is the total number of bytes that became
+	// scavenged without also changing allocated status.
+	NewlyScavengedBytes uint64
+	// UnchangedBytes is the total number of bytes whose allocated and
+	// scavenged status didn't change.
+	UnchangedBytes uint64
+}
+
+// Diff computes the per-page transitions between s and other, treating
+// s as the "before" state and other as the "after" state.
+func (s *State) Diff(other *State) *StateDiff {
+	minAddr := s.MinAddr()
+	if other.MinAddr() < minAddr {
+		minAddr = other.MinAddr()
+	}
+	maxAddr := s.MaxAddr()
+	if other.MaxAddr() > maxAddr {
+		maxAddr = other.MaxAddr()
+	}
+	d := &StateDiff{minAddr: minAddr}
+	if maxAddr <= minAddr {
+		return d
+	}
+	d.classes = make([]TransitionClass, (maxAddr-minAddr)/pageSize)
+	for i := range d.classes {
+		addr := minAddr + uint64(i)*pageSize
+		inBefore := addr >= s.MinAddr() && addr < s.MaxAddr()
+		inAfter := addr >= other.MinAddr() && addr < other.MaxAddr()
+		wasAlloc, isAlloc := s.IsAllocated(addr), other.IsAllocated(addr)
+		var c TransitionClass
+		switch {
+		case !wasAlloc && isAlloc:
+			c = NewlyAllocated
+			d.NewlyAllocatedBytes += pageSize
+		case wasAlloc && !isAlloc:
+			c = NewlyFreed
+			d.NewlyFreedBytes += pageSize
+		case inBefore && inAfter && !s.IsScavenged(addr) && other.IsScavenged(addr):
+			// Only a page both states actually cover, and that went
+			// from unscavenged to scavenged, counts: IsScavenged
+			// treats out-of-range addresses as scavenged by
+			// convention, which would otherwise make every
+			// unscavenged page of s that other doesn't cover look
+			// "newly scavenged".
+			c = NewlyScavenged
+			d.NewlyScavengedBytes += pageSize
+		default:
+			c = Unchanged
+			d.UnchangedBytes += pageSize
+		}
+		d.classes[i] = c
+	}
+	return d
+}
+
+// MinAddr returns the minimum address covered by the diff.
+func (d *StateDiff) MinAddr() uint64 {
+	return d.minAddr
+}
+
+// MaxAddr returns the maximum address covered by the diff.
+func (d *StateDiff) MaxAddr() uint64 {
+	return d.minAddr + uint64(len(d.classes))*pageSize
+}
+
+// ClassAt returns the transition class of the page containing addr, or
+// Unchanged if addr falls outside the range covered by the diff.
+func (d *StateDiff) ClassAt(addr uint64) TransitionClass {
+	if addr < d.MinAddr() || addr >= d.MaxAddr() {
+		return Unchanged
+	}
+	return d.classes[(addr-d.minAddr)/pageSize]
+}
+
+// PagesChanged returns the number of pages whose transition class isn't
+// Unchanged.
+func (d *StateDiff) PagesChanged() int {
+	n := 0
+	for _, c := range d.classes {
+		if c != Unchanged {
+			n++
+		}
+	}
+	return n
+}
+
 // Clone makes a copy of the State.
 func (s *State) Clone() *State {
 	s2 := *s
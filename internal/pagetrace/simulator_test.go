@@ -0,0 +1,60 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import "testing"
+
+func feedAll(s *Simulator, events ...Event) {
+	for _, e := range events {
+		s.Feed(e)
+	}
+}
+
+// TestStateDiffScavengeAcrossRange checks that Diff only classifies a
+// page as NewlyScavenged when both states actually cover that address
+// and the page genuinely went from unscavenged to scavenged, not
+// merely because IsScavenged defaults to true for addresses outside a
+// State's own range.
+func TestStateDiffScavengeAcrossRange(t *testing.T) {
+	var before Simulator
+	feedAll(&before,
+		Event{Kind: EventAllocate, Base: 0, Size: 3 * pageSize},
+		Event{Kind: EventFree, Base: 1 * pageSize, Size: pageSize},
+	)
+	beforeState := before.Snapshot().Clone()
+
+	var after Simulator
+	feedAll(&after,
+		Event{Kind: EventAllocate, Base: 0, Size: 3 * pageSize},
+		Event{Kind: EventFree, Base: 1 * pageSize, Size: pageSize},
+		Event{Kind: EventScavenge, Base: 1 * pageSize, Size: pageSize},
+		// Grow the address space with pages before never saw, one of
+		// which is freed but never scavenged.
+		Event{Kind: EventAllocate, Base: 3 * pageSize, Size: 100 * pageSize},
+		Event{Kind: EventFree, Base: 3 * pageSize, Size: pageSize},
+	)
+	afterState := after.Snapshot().Clone()
+
+	diff := beforeState.Diff(afterState)
+
+	if got, want := diff.ClassAt(1*pageSize), NewlyScavenged; got != want {
+		t.Errorf("ClassAt(page 1) = %v, want %v", got, want)
+	}
+	if got, want := diff.ClassAt(3*pageSize), Unchanged; got != want {
+		t.Errorf("ClassAt(page 3) = %v, want %v (freed-but-unscavenged page that before's events never touched must not be mislabeled)", got, want)
+	}
+	if got, want := diff.NewlyScavengedBytes, uint64(pageSize); got != want {
+		t.Errorf("NewlyScavengedBytes = %d, want %d", got, want)
+	}
+
+	// In the reverse direction, afterState's unscavenged pages past
+	// the end of beforeState's range (such as its trailing bitmap
+	// padding page) are out of range for the "after" state, where
+	// IsScavenged reports true by convention; none may count as newly
+	// scavenged.
+	if got := afterState.Diff(beforeState).NewlyScavengedBytes; got != 0 {
+		t.Errorf("reverse NewlyScavengedBytes = %d, want 0", got)
+	}
+}
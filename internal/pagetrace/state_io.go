@@ -0,0 +1,67 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ByteSize returns the approximate number of bytes of memory used to
+// hold s's bitmaps. It's meant for cache bookkeeping, not as a
+// precise measurement.
+func (s *State) ByteSize() int {
+	return len(s.allocBits) + len(s.scavBits)
+}
+
+// WriteTo encodes s as a gzip-compressed bitmap and writes it to w, so
+// that it can later be recovered with ReadState. The encoding is
+// private to this package and not a stable format.
+func (s *State) WriteTo(w io.Writer) (int64, error) {
+	gw := gzip.NewWriter(w)
+	var hdr [16]byte
+	binary.LittleEndian.PutUint64(hdr[0:8], s.minAddr)
+	binary.LittleEndian.PutUint64(hdr[8:16], uint64(len(s.allocBits)))
+	if _, err := gw.Write(hdr[:]); err != nil {
+		return 0, err
+	}
+	if _, err := gw.Write(s.allocBits); err != nil {
+		return 0, err
+	}
+	if _, err := gw.Write(s.scavBits); err != nil {
+		return 0, err
+	}
+	if err := gw.Close(); err != nil {
+		return 0, err
+	}
+	return int64(len(hdr) + len(s.allocBits) + len(s.scavBits)), nil
+}
+
+// ReadState decodes a State previously written with (*State).WriteTo.
+func ReadState(r io.Reader) (*State, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading cached state: %v", err)
+	}
+	defer gr.Close()
+	var hdr [16]byte
+	if _, err := io.ReadFull(gr, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading cached state header: %v", err)
+	}
+	s := &State{
+		minAddr:   binary.LittleEndian.Uint64(hdr[0:8]),
+		allocBits: make([]byte, binary.LittleEndian.Uint64(hdr[8:16])),
+	}
+	s.scavBits = make([]byte, len(s.allocBits))
+	if _, err := io.ReadFull(gr, s.allocBits); err != nil {
+		return nil, fmt.Errorf("reading cached alloc bitmap: %v", err)
+	}
+	if _, err := io.ReadFull(gr, s.scavBits); err != nil {
+		return nil, fmt.Errorf("reading cached scavenged bitmap: %v", err)
+	}
+	return s, nil
+}
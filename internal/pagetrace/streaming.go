@@ -0,0 +1,235 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StreamingParser parses a page trace in a single forward pass over
+// an io.Reader, merging per-P event streams as they're decoded
+// instead of pre-scanning the whole trace for per-P block offsets the
+// way NewTrace and Parser do. This avoids NewTrace's random-access
+// pre-pass, which doubles I/O and requires an io.ReaderAt; a
+// StreamingParser only ever reads forward, so it can consume a trace
+// being written to a pipe or downloaded over the network.
+//
+// The cost is that events can only be merged among the Ps discovered
+// so far: if a P that hasn't appeared in the stream yet eventually
+// produces an event earlier than one StreamingParser has already
+// returned, that ordering violation isn't detected. This holds for
+// any trace where every P starts recording at or after the beginning
+// of the trace, which is true of every trace this package has
+// encountered in practice.
+type StreamingParser struct {
+	r    io.Reader
+	buf  [32 << 10]byte
+	n, i int
+	eof  bool
+
+	expectNpagesTrailer   bool
+	npagesTrailerBaseAddr uint64
+	wantTime              bool
+	curPidx               int32
+	curSyncTime           int64
+
+	pendingKind eventKind
+	pendingP    int32
+	pendingTime int64
+
+	pending map[int32][]Event
+	procs   []int32 // Ps seen so far, in discovery order
+
+	haveStart bool
+	startTime int64 // the first sync timestamp seen; Event.Time's epoch
+
+	minAddr, maxAddr uint64
+	endTime          int64 // the maximum curTime seen so far, same units as startTime
+}
+
+// NewStreamingParser creates a StreamingParser that reads a page
+// trace from r.
+func NewStreamingParser(r io.Reader) (*StreamingParser, error) {
+	return &StreamingParser{r: r, pending: make(map[int32][]Event), curPidx: -1}, nil
+}
+
+// MinAddr returns the minimum address of any event decoded so far.
+// Like MaxAddr and TimeEnd, it's a running value: it only reflects
+// the trace up to whatever point Next has reached.
+func (p *StreamingParser) MinAddr() uint64 { return p.minAddr }
+
+// MaxAddr returns the maximum address of any event decoded so far.
+func (p *StreamingParser) MaxAddr() uint64 { return p.maxAddr }
+
+// TimeEnd returns the latest timestamp of any event or sync record
+// decoded so far, relative to the first sync event seen.
+func (p *StreamingParser) TimeEnd() time.Duration {
+	return time.Duration(p.endTime - p.startTime)
+}
+
+// Next returns the next event, merged in time order across every P
+// discovered so far.
+//
+// Returns io.EOF once the underlying reader is exhausted and every
+// discovered P's queue has been drained.
+func (p *StreamingParser) Next() (Event, error) {
+	for {
+		for _, proc := range p.procs {
+			if len(p.pending[proc]) == 0 {
+				if err := p.fill(proc); err != nil && err != io.EOF {
+					return Event{}, err
+				}
+			}
+		}
+		bestProc, found := int32(0), false
+		for _, proc := range p.procs {
+			es := p.pending[proc]
+			if len(es) == 0 {
+				continue
+			}
+			if !found || es[0].Time < p.pending[bestProc][0].Time {
+				bestProc, found = proc, true
+			}
+		}
+		if found {
+			e := p.pending[bestProc][0]
+			p.pending[bestProc] = p.pending[bestProc][1:]
+			return e, nil
+		}
+		if err := p.decodeOne(); err != nil {
+			return Event{}, err
+		}
+	}
+}
+
+// fill decodes forward until pidx has at least one pending event, or
+// the underlying reader is exhausted.
+func (p *StreamingParser) fill(pidx int32) error {
+	for len(p.pending[pidx]) == 0 {
+		if err := p.decodeOne(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next8 reads the next 8-byte word from the underlying reader.
+func (p *StreamingParser) next8() (uint64, error) {
+	for p.i+8 > p.n {
+		copy(p.buf[:], p.buf[p.i:p.n])
+		p.n -= p.i
+		p.i = 0
+		if p.eof {
+			if p.n == 0 {
+				return 0, io.EOF
+			}
+			return 0, fmt.Errorf("malformed trace: not a multiple of 8 in size")
+		}
+		n, err := p.r.Read(p.buf[p.n:])
+		p.n += n
+		if err == io.EOF {
+			p.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	v := binary.LittleEndian.Uint64(p.buf[p.i : p.i+8])
+	p.i += 8
+	return v, nil
+}
+
+// decodeOne decodes the next word (and, for a large event, its
+// trailer) from the underlying reader, updating parser state and
+// appending a complete event to its P's pending queue. It mirrors the
+// decode loop in NewTraceOptions, but one word at a time so that
+// state can persist across calls instead of across loop iterations.
+func (p *StreamingParser) decodeOne() error {
+	u, err := p.next8()
+	if err != nil {
+		return err
+	}
+
+	if p.expectNpagesTrailer {
+		npages := u
+		if max := p.npagesTrailerBaseAddr + npages*pageSize; p.maxAddr == 0 || max > p.maxAddr {
+			p.maxAddr = max
+		}
+		p.expectNpagesTrailer = false
+		p.appendEvent(p.pendingP, p.pendingKind, p.npagesTrailerBaseAddr, npages, p.pendingTime)
+		return nil
+	}
+
+	e := eventHeader(u)
+	var curTime int64
+	if e.kind() != pid {
+		if e.kind() == sync {
+			curTime = e.timestamp()
+			p.curSyncTime = curTime
+		} else {
+			if e.large() {
+				p.expectNpagesTrailer = true
+			}
+			min := e.base()
+			if p.minAddr == 0 || min < p.minAddr {
+				p.minAddr = min
+			}
+			if e.large() {
+				p.npagesTrailerBaseAddr = min
+			} else if max := min + e.npagesSmall()*pageSize; p.maxAddr == 0 || max > p.maxAddr {
+				p.maxAddr = max
+			}
+			curTime = p.curSyncTime + e.timestampDelta()
+		}
+		if curTime > p.endTime {
+			p.endTime = curTime
+		}
+	}
+
+	if p.wantTime {
+		if e.kind() != sync {
+			return fmt.Errorf("expected sync event immediately following pid event")
+		}
+		if !p.haveStart {
+			p.startTime = curTime
+			p.haveStart = true
+		}
+		p.wantTime = false
+		return nil
+	}
+
+	switch e.kind() {
+	case pid:
+		p.curPidx = e.pid()
+		if _, ok := p.pending[p.curPidx]; !ok {
+			p.pending[p.curPidx] = nil
+			p.procs = append(p.procs, p.curPidx)
+		}
+		p.wantTime = true
+	case sync:
+		// curSyncTime and endTime are already updated above.
+	default:
+		if e.large() {
+			p.pendingKind = e.kindNoLarge()
+			p.pendingP = p.curPidx
+			p.pendingTime = curTime
+		} else {
+			p.appendEvent(p.curPidx, e.kindNoLarge(), e.base(), e.npagesSmall(), curTime)
+		}
+	}
+	return nil
+}
+
+func (p *StreamingParser) appendEvent(proc int32, k eventKind, base, npages uint64, curTime int64) {
+	p.pending[proc] = append(p.pending[proc], Event{
+		Kind: Kind(k),
+		P:    proc,
+		Time: time.Duration(curTime - p.startTime),
+		Base: base,
+		Size: pageSize * npages,
+	})
+}
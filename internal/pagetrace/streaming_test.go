@@ -0,0 +1,84 @@
+// Copyright 2022 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pagetrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// writeBlock appends a pid+sync header followed by one event per
+// (kind, base, delta) triple in evts to buf, mirroring one block of
+// the wire format encodeRawEvents produces for a single P.
+func writeBlock(buf *bytes.Buffer, proc int32, syncTime int64, evts [][3]int64) {
+	var hdr [8]byte
+	write := func(h eventHeader) {
+		binary.LittleEndian.PutUint64(hdr[:], uint64(h))
+		buf.Write(hdr[:])
+	}
+	write(makePidHeader(proc))
+	write(makeSyncHeader(syncTime))
+	for _, e := range evts {
+		write(makeEventHeader(eventKind(e[0]), uint64(e[1]), 1, e[2]))
+	}
+}
+
+// TestStreamingParserMergesAcrossPs checks that StreamingParser merges
+// events from several Ps into a single time-ordered stream even when
+// each P's events arrive in their own interleaved blocks throughout
+// the trace, the way a runtime recording multiple concurrently
+// scheduled Ps would produce them -- not just within a single block
+// per P.
+func TestStreamingParserMergesAcrossPs(t *testing.T) {
+	var buf bytes.Buffer
+	// P0's first block, then P1's first block, then a second block
+	// for each P later in the trace. P1 isn't discovered until its
+	// first pid header is decoded partway through P0's events, and
+	// StreamingParser must still merge its later block's events
+	// against P0's rather than draining one P's queue before the
+	// other is even known.
+	writeBlock(&buf, 0, 12800, [][3]int64{{int64(alloc), 0, 0}})                 // P0 alloc @ 12800
+	writeBlock(&buf, 1, 12800, [][3]int64{{int64(alloc), pageSize, 6400}})       // P1 alloc @ 19200
+	writeBlock(&buf, 0, 32000, [][3]int64{{int64(free), 0, 0}})                  // P0 free  @ 32000
+	writeBlock(&buf, 1, 32000, [][3]int64{{int64(free), pageSize, 6400}})        // P1 free  @ 38400
+
+	sp, err := NewStreamingParser(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewStreamingParser: %v", err)
+	}
+	var got []Event
+	for {
+		e, err := sp.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, e)
+	}
+
+	want := []Event{
+		{Kind: EventAllocate, P: 0, Time: 0, Base: 0, Size: pageSize},
+		{Kind: EventAllocate, P: 1, Time: 6400, Base: pageSize, Size: pageSize},
+		{Kind: EventFree, P: 0, Time: 19200, Base: 0, Size: pageSize},
+		{Kind: EventFree, P: 1, Time: 25600, Base: pageSize, Size: pageSize},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Time < got[i-1].Time {
+			t.Errorf("events not in time order: event %d (%v) precedes event %d (%v)", i-1, got[i-1].Time, i, got[i].Time)
+		}
+	}
+}
@@ -8,24 +8,70 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sort"
 	"time"
 )
 
 // Trace represents a slice of a page trace in time.
 type Trace struct {
-	r            io.ReaderAt
-	blocks       [][]interval
-	minTraceTime int64
-	startTime    int64
-	endTime      int64
-	minAddr      uint64
-	maxAddr      uint64
+	r                io.ReaderAt
+	blocks           [][]interval
+	minTraceTime     int64
+	startTime        int64
+	endTime          int64
+	minAddr          uint64
+	maxAddr          uint64
+	checkpointEvents int
+	checkpoints      []stateCheckpoint
 }
 
-// NewTrace creates a new Trace from an encoded trace.
+// stateCheckpoint is a cached simulator State at a particular point in
+// the trace, used to seed a simulation partway through a large trace
+// instead of replaying from the beginning. See TraceOptions.
+type stateCheckpoint struct {
+	time  int64
+	state *State
+}
+
+// TraceOptions configures NewTraceOptions.
+type TraceOptions struct {
+	// CheckpointEvents controls how many trace events apart the
+	// States retained by CacheCheckpointStates are recorded. Larger
+	// values use less memory to hold the checkpoints, at the cost of
+	// StateAt returning a State further from the requested time. It
+	// has no effect unless CacheCheckpointStates is set, and Slice
+	// does not use checkpoints. The zero value uses a small built-in
+	// default.
+	CheckpointEvents int
+
+	// CacheCheckpointStates, if true, simulates the trace once while
+	// indexing it and retains a State at each checkpoint, accessible
+	// via Trace.StateAt. This trades memory (one State per
+	// checkpoint) for letting a caller cheaply seed a simulation
+	// partway through the trace instead of recomputing from the
+	// beginning.
+	CacheCheckpointStates bool
+}
+
+const defaultCheckpointEvents = 4096
+
+// NewTrace creates a new Trace from an encoded trace, using default
+// options. See NewTraceOptions to cache simulator checkpoint States
+// alongside the trace.
 //
 // The returned Trace represents the full trace from beginning to end.
 func NewTrace(r io.ReaderAt) (*Trace, error) {
+	return NewTraceOptions(r, TraceOptions{})
+}
+
+// NewTraceOptions is like NewTrace, but allows control over the
+// checkpoint States optionally cached while parsing the trace. See
+// TraceOptions.
+func NewTraceOptions(r io.ReaderAt, opts TraceOptions) (*Trace, error) {
+	checkpointEvents := opts.CheckpointEvents
+	if checkpointEvents <= 0 {
+		checkpointEvents = defaultCheckpointEvents
+	}
 	// Parse the trace once through to obtain some useful information about it.
 	var (
 		// Reader state.
@@ -147,9 +193,60 @@ func NewTrace(r io.ReaderAt) (*Trace, error) {
 	t.endTime = endTime
 	t.minAddr = minAddr
 	t.maxAddr = maxAddr
+	t.checkpointEvents = checkpointEvents
+
+	if opts.CacheCheckpointStates {
+		if err := t.buildCheckpoints(); err != nil {
+			return nil, err
+		}
+	}
 	return t, nil
 }
 
+// buildCheckpoints simulates t from the beginning once, recording a
+// State every checkpointEvents events so that StateAt can later hand
+// out a seed state without replaying the whole trace.
+func (t *Trace) buildCheckpoints() error {
+	parser := NewParser(t)
+	var sim Simulator
+	n := 0
+	for {
+		e, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sim.Feed(e)
+		n++
+		if n%t.checkpointEvents == 0 {
+			t.checkpoints = append(t.checkpoints, stateCheckpoint{
+				time:  t.minTraceTime + int64(e.Time),
+				state: sim.Snapshot().Clone(),
+			})
+		}
+	}
+	return nil
+}
+
+// StateAt returns the cached simulator State nearest to, but not
+// after, the given time, provided TraceOptions.CacheCheckpointStates
+// was set when t was created via NewTraceOptions. It's meant to give a
+// cheap seed state for a large trace instead of replaying from the
+// beginning.
+func (t *Trace) StateAt(at time.Duration) (*State, bool) {
+	if len(t.checkpoints) == 0 {
+		return nil, false
+	}
+	target := t.minTraceTime + int64(at)
+	i := sort.Search(len(t.checkpoints), func(i int) bool { return t.checkpoints[i].time > target })
+	if i == 0 {
+		return nil, false
+	}
+	return t.checkpoints[i-1].state, true
+}
+
 // Duration returns the real monotonic wall-time duration during which
 // the trace was taken.
 func (t *Trace) Duration() time.Duration {
@@ -163,6 +260,10 @@ func (t *Trace) Clone() *Trace {
 	t2.startTime = t.startTime
 	t2.endTime = t.endTime
 	t2.minTraceTime = t.minTraceTime
+	t2.minAddr = t.minAddr
+	t2.maxAddr = t.maxAddr
+	t2.checkpointEvents = t.checkpointEvents
+	t2.checkpoints = t.checkpoints
 	t2.blocks = make([][]interval, len(t.blocks))
 	for i := range t.blocks {
 		t2.blocks[i] = make([]interval, len(t.blocks[i]))
@@ -233,13 +334,23 @@ func (t *Trace) Slice(s, e time.Duration) *Trace {
 	}
 	t2.startTime = start
 	t2.endTime = end
+	t2.checkpointEvents = t.checkpointEvents
 	for i := range t.blocks {
-		for _, iv := range t.blocks[i] {
-			if iv.endTime < start || iv.startTime > end {
-				continue
-			}
-			t2.blocks[i] = append(t2.blocks[i], iv)
+		blocks := t.blocks[i]
+		// Blocks are appended in increasing time order as the trace is
+		// parsed, so find the overlapping range with two binary
+		// searches instead of scanning every block.
+		lo := sort.Search(len(blocks), func(j int) bool { return blocks[j].endTime >= start })
+		hi := sort.Search(len(blocks), func(j int) bool { return blocks[j].startTime > end })
+		if lo < hi {
+			t2.blocks[i] = append(t2.blocks[i], blocks[lo:hi]...)
+		}
+	}
+	for _, c := range t.checkpoints {
+		if c.time < start || c.time > end {
+			continue
 		}
+		t2.checkpoints = append(t2.checkpoints, c)
 	}
 	return t2
 }